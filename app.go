@@ -3,143 +3,256 @@ package app
 import (
 	"context"
 	"errors"
-	"os"
-	"os/signal"
+	"fmt"
+	"net/http"
 	"regexp"
-	"sync"
-	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/oauth2"
 
-	"github.com/google/go-github/v32/github"
+	"github.com/google/go-github/v75/github"
 )
 
 // App is the main application container
 type App struct {
-	Owner          *string
-	Repo           *string
-	RunId          *int64
-	MinBytes       int64
-	MaxBytes       *int64
-	Name           string
-	Pattern        string
-	DryRun         bool
-	ActiveDuration string
-	context        *context.Context
-	client         *github.Client
+	Owner            *string
+	Repo             *string
+	RunId            *int64
+	MinBytes         int64
+	MaxBytes         *int64
+	Name             string
+	Pattern          string
+	DryRun           bool
+	ActiveDuration   string
+	Policy           *Policy
+	KeepLast         int
+	KeepLastByBranch bool
+	Budget           *int64
+	BudgetOrder      string
+	Workflow         string
+	Branch           string
+	HeadSHA          string
+	Event            string
+	Conclusion       string
+	ClosedPRs        bool
+	ClosedPRGrace    time.Duration
+	Protect          []string
+	Org              string
+	RepoPattern      string
+	Topic            string
+	Visibility       string
+	IncludeArchived  bool
+	RepoConcurrency  int
+	Concurrency      int
+	MaxDelete        int
+	MaxDeleteBytes   int64
+	MaxDeleteOrder   string
+	PageConcurrency  int
+	Retry            RetryPolicy
+	Timeouts         Timeouts
+	PlanKey          []byte
+	Extract          bool
+	StatsRuns        bool
+	context          *context.Context
+	logger           log.FieldLogger
+	artifacts        ArtifactService
+	githubApp        *GitHubApp
+	endpoint         *Endpoint
+	repositories     RepositoryService
+	workflowRuns     WorkflowRunService
+	pullRequests     PullRequestService
+	tags             TagService
+	selector         Selector
+	downloader       ArtifactDownloader
+	archive          ArchiveStore
+	downloads        *http.Client
+	listing          bool
+	runs             *runCache
 }
 
-// Run the application
-func (a *App) Run() error {
+// Run the application, reporting the outcome of every matched artifact. Failing to delete individual artifacts
+// is not an error; see Report.Result. When ctx is cancelled, in-flight deletions are allowed to finish, and the
+// partial report is returned along with the context's error.
+func (a *App) Run(ctx context.Context) (*Report, error) {
 	err := a.checkPreconditions()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if len(a.Org) > 0 {
+		return a.runOrg(ctx)
 	}
 
-	log.WithFields(log.Fields{"owner": *a.Owner, "repo": *a.Repo}).Info("delete-artifacts is checking the repo")
+	return a.runRepo(ctx)
+}
+
+// runRepo deletes the artifacts of the repository identified by Owner and Repo
+func (a *App) runRepo(ctx context.Context) (*Report, error) {
+	report := newReport(*a.Owner, *a.Repo)
+	a.log().WithFields(log.Fields{"owner": *a.Owner, "repo": *a.Repo}).Info("delete-artifacts is checking the repo")
 
-	executionContext, cancel := context.WithTimeout(*a.context, 2*time.Minute)
-	defer cancel()
+	executionContext, cancel := within(ctx, a.Timeouts.Run)
+	defer func() { cancel() }()
 
-	wg := sync.WaitGroup{}
-	doneChan := make(chan error)
-	errorChan := make(chan error)
-	itemsChan := make(chan []*github.Artifact)
+	if !a.needsFullListing() {
+		if err := a.streamArtifacts(executionContext, report); err != nil {
+			report.Err = err
+			return report, err
+		}
+		return report, nil
+	}
+
+	all, err := a.selectArtifacts(executionContext, report)
+	if err != nil {
+		report.Err = err
+		return report, err
+	}
+
+	report.addMatched(len(all))
+	a.logEvictions(all)
+	all, err = a.limitDeletions(all, report)
+	if err != nil {
+		report.Err = err
+		return report, err
+	}
+	if a.selector != nil {
+		all, err = a.confirmDeletions(ctx, all, report)
+		if err != nil {
+			report.Err = err
+			return report, err
+		}
+		// time spent reviewing the artifacts doesn't count toward the run timeout
+		cancel()
+		executionContext, cancel = within(ctx, a.Timeouts.Run)
+	}
+	a.deleteSelected(executionContext, all, report)
+	if err := ctx.Err(); err != nil {
+		report.Err = err
+		return report, err
+	}
+	return report, nil
+}
+
+// deleteSelected deletes the selected artifacts, or reports them as skipped during a dry run
+func (a *App) deleteSelected(ctx context.Context, all []*github.Artifact, report *Report) {
+	if len(all) == 0 {
+		a.log().Info("No artifacts to delete!")
+		return
+	}
 
-	signalChannel := make(chan os.Signal, 1)
-	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
+	a.log().WithFields(log.Fields{"count": len(all)}).Debug("Total number of artifacts to delete.")
+	if a.DryRun {
+		a.skipDryRun(all, report)
+		return
+	}
+	a.deleteArtifacts(ctx, all, report)
+}
 
-	wg.Add(1)
-	go func(page int) {
-		a.retrieveArtifactsByPage(&wg, &executionContext, page, itemsChan, errorChan)
-	}(1)
+// skipDryRun reports the artifacts which would have been deleted as skipped
+func (a *App) skipDryRun(artifacts []*github.Artifact, report *Report) {
+	for _, artifact := range artifacts {
+		if a.listing {
+			a.log().WithFields(log.Fields{"size": artifact.GetSizeInBytes(), "name": artifact.GetName()}).Debug("Listed artifact")
+			report.skipped(artifact, reasonListed)
+			continue
+		}
+		a.log().WithFields(log.Fields{"size": artifact.GetSizeInBytes(), "name": artifact.GetName()}).
+			Warn("DryRun: would have deleted the artifact")
+		report.skipped(artifact, "dry run")
+	}
+}
 
-	go wait(doneChan, &wg)
+// selectArtifacts lists the artifacts of the repository and returns those which the filters slate for deletion. Those
+// matched but protected are recorded as skipped in the report, when there is one.
+func (a *App) selectArtifacts(executionContext context.Context, report *Report) ([]*github.Artifact, error) {
+	return a.selectListed(executionContext, report, nil)
+}
 
+// selectListed selects artifacts as selectArtifacts does, calling visit with each page of listed artifacts, once the
+// runs of its artifacts are resolved, when visit is set
+func (a *App) selectListed(executionContext context.Context, report *Report, visit func(items []*github.Artifact)) ([]*github.Artifact, error) {
 	all := make([]*github.Artifact, 0)
-	for {
-		select {
-		case sig := <-signalChannel:
-			log.Warn("Received signal: ", sig)
-			os.Exit(0)
-		case e := <-errorChan:
-			return e
-		case items := <-itemsChan:
-			if items != nil {
-				filtered := a.filterArtifacts(items)
-				if len(filtered) > 0 {
-					log.WithFields(log.Fields{"count": len(filtered)}).Debug("Found a set of artifacts for slated deletion.")
-					all = append(all, filtered...)
-				}
-			}
-		case <-doneChan:
-			if len(all) == 0 {
-				log.Info("No artifacts to delete!")
-			} else {
-				log.WithFields(log.Fields{"count": len(all)}).Debug("Total number of artifacts to delete.")
-				if a.DryRun {
-					for _, artifact := range all {
-						log.WithFields(log.Fields{"size": artifact.GetSizeInBytes(), "name": artifact.GetName()}).
-							Warn("DryRun: would have deleted the artifact")
-					}
-				} else {
-					// perform the deletions. Synchronously is fine here.
-					for _, artifact := range all {
-						log.WithFields(log.Fields{"size": artifact.GetSizeInBytes(), "name": artifact.GetName()}).
-							Info("Deleting artifact")
-						_, err := a.client.Actions.DeleteArtifact(executionContext, *a.Owner, *a.Repo, artifact.GetID())
-						if err != nil {
-							log.Warnf("Error deleting %s (artifact ID %d), ignoring…", artifact.GetName(), artifact.GetID())
-						}
-					}
-				}
-			}
-			return nil
+	// listed holds the full listing for selections which can't be decided a page at a time
+	listed := make([]*github.Artifact, 0)
+	err := a.listArtifacts(executionContext, &rateGate{}, func(items []*github.Artifact) bool {
+		if a.needsFullListing() {
+			listed = append(listed, items...)
+		}
+		a.resolveRuns(executionContext, items)
+		if visit != nil {
+			visit(items)
+		}
+		filtered, protected := a.protect(a.matchArtifacts(items))
+		if report != nil {
+			report.addMatched(len(protected))
+		}
+		a.reportProtected(protected, report)
+		if len(filtered) > 0 {
+			a.log().WithFields(log.Fields{"count": len(filtered)}).Debug("Found a set of artifacts for slated deletion.")
+			all = append(all, filtered...)
 		}
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	all = a.retainNewest(listed, all)
+	all = a.applyBudget(listed, all)
+	return all, nil
 }
 
+// filterArtifacts returns the artifacts which the filters slate for deletion. Protected artifacts are never returned.
 func (a *App) filterArtifacts(artifacts []*github.Artifact) []*github.Artifact {
+	filtered, _ := a.protect(a.matchArtifacts(artifacts))
+	return filtered
+}
+
+// matchArtifacts returns the artifacts matching the filters, regardless of the protection rules
+func (a *App) matchArtifacts(artifacts []*github.Artifact) []*github.Artifact {
+	// a policy replaces the individual filters entirely
+	if a.Policy != nil {
+		return a.Policy.filterArtifacts(a.log(), artifacts, a.runOf)
+	}
+
 	filtered := make([]*github.Artifact, 0)
 	for _, artifact := range artifacts {
-		log.WithFields(log.Fields{"size": artifact.GetSizeInBytes(), "name": artifact.GetName()}).Debug("Iterating artifact.")
+		a.log().WithFields(log.Fields{"size": artifact.GetSizeInBytes(), "name": artifact.GetName()}).Debug("Iterating artifact.")
 		shouldAdd := false
 		size := artifact.GetSizeInBytes()
 		// note MinBytes is required. it will short-circuit all other checks
 		if size >= a.MinBytes {
-			log.WithFields(log.Fields{"MinBytes": a.MinBytes}).Debug("MinBytes filter has matched.")
+			a.log().WithFields(log.Fields{"MinBytes": a.MinBytes}).Debug("MinBytes filter has matched.")
 			shouldAdd = true
 		}
 
 		if shouldAdd && a.MaxBytes != nil && size > *a.MaxBytes {
-			log.WithFields(log.Fields{"MaxBytes": *a.MaxBytes}).Debug("MaxBytes filter has matched.")
+			a.log().WithFields(log.Fields{"MaxBytes": *a.MaxBytes}).Debug("MaxBytes filter has matched.")
 			shouldAdd = false
 		}
 
 		if shouldAdd && len(a.Name) > 0 {
 			shouldAdd = artifact.GetName() == a.Name
-			log.WithFields(log.Fields{"Name": artifact.GetName(), "match": shouldAdd}).Debug("Name filter condition.")
+			a.log().WithFields(log.Fields{"Name": artifact.GetName(), "match": shouldAdd}).Debug("Name filter condition.")
 		}
 
 		if shouldAdd && len(a.ActiveDuration) > 0 {
 			duration, err := time.ParseDuration(a.ActiveDuration)
 			if err != nil || duration <= 0 {
-				log.WithFields(log.Fields{"ActiveDuration": a.ActiveDuration, "see": "https://golang.org/pkg/time/#ParseDuration"}).
+				a.log().WithFields(log.Fields{"ActiveDuration": a.ActiveDuration, "see": "https://golang.org/pkg/time/#ParseDuration"}).
 					Error("Failed to parse as positive duration string. Artifact will not match ANY conditions.")
 				shouldAdd = false
 			} else {
 				mustBeBefore := time.Now().Add(-duration)
 				shouldAdd = artifact.GetCreatedAt().Before(mustBeBefore)
-				log.WithFields(log.Fields{"ActiveDuration": a.ActiveDuration, "match": shouldAdd}).Debug("ActiveDuration filter condition.")
+				a.log().WithFields(log.Fields{"ActiveDuration": a.ActiveDuration, "match": shouldAdd}).Debug("ActiveDuration filter condition.")
 			}
 		}
 
 		if shouldAdd && len(a.Pattern) > 0 {
 			re, err := regexp.CompilePOSIX(a.Pattern)
 			if err != nil {
-				log.WithFields(log.Fields{"pattern": a.Pattern}).
+				a.log().WithFields(log.Fields{"pattern": a.Pattern}).
 					Error("Failed to compile the pattern. Artifact will not match ANY conditions.")
 				shouldAdd = false
 			} else {
@@ -147,91 +260,127 @@ func (a *App) filterArtifacts(artifacts []*github.Artifact) []*github.Artifact {
 			}
 		}
 
+		if shouldAdd && !a.runFilter().empty() {
+			shouldAdd = a.runFilter().matches(a.runOf(artifact))
+			a.log().WithFields(log.Fields{"runId": artifact.GetWorkflowRun().GetID(), "match": shouldAdd}).Debug("Workflow run filter condition.")
+		}
+
+		if shouldAdd && a.ClosedPRs {
+			shouldAdd = a.closedPullRequests(a.runOf(artifact))
+			a.log().WithFields(log.Fields{"runId": artifact.GetWorkflowRun().GetID(), "match": shouldAdd}).Debug("Closed pull request filter condition.")
+		}
+
 		if shouldAdd {
 			filtered = append(filtered, artifact)
 		} else {
-			log.Debug("filterArtifacts had no matches this time.")
+			a.log().Debug("filterArtifacts had no matches this time.")
 		}
 	}
 	return filtered
 }
 
-func (a *App) retrieveArtifactsByPage(wg *sync.WaitGroup, parent *context.Context, page int, itemsChan chan []*github.Artifact, errChan chan error) {
-	ctx, timeout := context.WithTimeout(*parent, 30*time.Second)
-	defer timeout()
-	defer wg.Done()
+// needsFullListing is true when the selection of artifacts to delete depends on every listed artifact, or deletions
+// are capped or confirmed, since that happens before deleting any artifact
+func (a *App) needsFullListing() bool {
+	return a.KeepLast > 0 || a.Budget != nil || a.limited() || a.selector != nil
+}
 
-	var err error
-	var list *github.ArtifactList
-	opts := &github.ListOptions{PerPage: 100, Page: page}
-	if a.RunId != nil {
-		log.WithFields(log.Fields{"runId": *a.RunId}).Debug("Querying artifacts for a specific run.")
-		list, _, err = a.client.Actions.ListWorkflowRunArtifacts(ctx, *a.Owner, *a.Repo, *a.RunId, opts)
-	} else {
-		log.Debug("Querying artifacts across all workflows.")
-		list, _, err = a.client.Actions.ListArtifacts(ctx, *a.Owner, *a.Repo, opts)
+func (a *App) checkPreconditions() error {
+	if len(a.Org) > 0 {
+		if err := a.checkOrgPreconditions(); err != nil {
+			return err
+		}
+	} else if err := a.checkRepoPreconditions(); err != nil {
+		return err
 	}
 
-	if err != nil {
-		errChan <- err
-		return
+	if a.KeepLast < 0 {
+		return errors.New("keep-last must not be negative")
+	}
+	if a.Budget != nil && *a.Budget < 0 {
+		return errors.New("budget must not be negative")
+	}
+	if err := a.checkLimits(); err != nil {
+		return err
+	}
+	if err := a.checkServices(); err != nil {
+		return err
+	}
+	if a.ClosedPRs && a.Policy != nil {
+		return errors.New("closed pull requests can't be combined with a policy, which replaces the individual filters")
+	}
+	if a.ClosedPRGrace < 0 {
+		return errors.New("closed pull request grace period must not be negative")
+	}
+	if _, err := a.protections(); err != nil {
+		return err
+	}
+	if order := a.budgetOrder(); order != BudgetOrderOldest && order != BudgetOrderLargest {
+		return fmt.Errorf("budget order %q is invalid, expected %s or %s", order, BudgetOrderOldest, BudgetOrderLargest)
 	}
 
-	if len(list.Artifacts) > 0 {
-		itemsChan <- list.Artifacts
-
-		wg.Add(1)
+	return nil
+}
 
-		go func(p int) {
-			a.retrieveArtifactsByPage(wg, parent, p, itemsChan, errChan)
-		}(page + 1)
-	} else {
-		log.Debug("Zero artifacts remaining for query.")
+func (a *App) checkLimits() error {
+	if a.MaxDelete < 0 {
+		return errors.New("max-delete must not be negative")
+	}
+	if a.MaxDeleteBytes < 0 {
+		return errors.New("max-delete-bytes must not be negative")
 	}
+	if order := a.maxDeleteOrder(); order != MaxDeleteAbort && order != MaxDeleteOldest && order != MaxDeleteLargest {
+		return fmt.Errorf("max-delete order %q is invalid, expected %s, %s or %s", order, MaxDeleteAbort, MaxDeleteOldest, MaxDeleteLargest)
+	}
+	return nil
 }
 
-func wait(ch chan error, wg *sync.WaitGroup) {
-	wg.Wait()
-	ch <- nil
+// checkServices checks the services needed by the filters in effect are available
+func (a *App) checkServices() error {
+	if a.needsRuns() && a.workflowRuns == nil {
+		return errors.New("workflow, event, conclusion and closed pull request filters require a WorkflowRunService")
+	}
+	if a.ClosedPRs && a.pullRequests == nil {
+		return errors.New("closed pull request filters require a PullRequestService")
+	}
+	if a.archive != nil && a.downloader == nil {
+		return errors.New("archiving requires an ArtifactDownloader, see WithArtifactDownloader")
+	}
+	if store, ok := a.archive.(interface{ validate() error }); ok {
+		if err := store.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (a *App) checkPreconditions() error {
-	if len(*a.Owner) <= 1 {
+func (a *App) checkRepoPreconditions() error {
+	if a.Owner == nil || len(*a.Owner) <= 1 {
 		return errors.New("owner is invalid")
 	}
-	if len(*a.Repo) <= 1 {
+	if a.Repo == nil || len(*a.Repo) <= 1 {
 		return errors.New("repo is invalid")
 	}
 
 	return nil
 }
 
-// New creates an instance of App
+// New creates an instance of App, authenticated via the GITHUB_TOKEN environment variable
 func New(owner *string, repo *string, runId *int64, minBytes int64, maxBytes *int64, name string, pattern string, activeDuration string, dryRun bool) (*App, error) {
-	token, found := os.LookupEnv("GITHUB_TOKEN")
-	if !found {
-		return nil, errors.New("GITHUB_TOKEN environment variable is missing")
-	}
-	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	client := github.NewClient(tc)
-
-	app := &App{
-		Owner:          owner,
-		Repo:           repo,
-		RunId:          runId,
-		MinBytes:       minBytes,
-		MaxBytes:       maxBytes,
-		Name:           name,
-		Pattern:        pattern,
-		DryRun:         dryRun,
-		ActiveDuration: activeDuration,
-		context:        &ctx,
-		client:         client,
+	app, err := NewWithOptions()
+	if err != nil {
+		return nil, err
 	}
 
+	app.Owner = owner
+	app.Repo = repo
+	app.RunId = runId
+	app.MinBytes = minBytes
+	app.MaxBytes = maxBytes
+	app.Name = name
+	app.Pattern = pattern
+	app.DryRun = dryRun
+	app.ActiveDuration = activeDuration
+
 	return app, nil
 }
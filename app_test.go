@@ -4,7 +4,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/google/go-github/v32/github"
+	"github.com/google/go-github/v75/github"
 )
 
 // Helper function to create a pointer to an int64
@@ -22,6 +22,37 @@ func createArtifact(name string, sizeInBytes int64, createdAt time.Time) *github
 	}
 }
 
+// artifactOption sets a field of an artifact created by newArtifact
+type artifactOption func(artifact *github.Artifact)
+
+// newArtifact creates a test artifact created an hour ago, with options setting any other fields
+func newArtifact(name string, sizeInBytes int64, options ...artifactOption) *github.Artifact {
+	artifact := createArtifact(name, sizeInBytes, time.Now().Add(-time.Hour))
+	for _, option := range options {
+		option(artifact)
+	}
+	return artifact
+}
+
+func withID(id int64) artifactOption {
+	return func(artifact *github.Artifact) {
+		artifact.ID = &id
+	}
+}
+
+func withCreatedAt(createdAt time.Time) artifactOption {
+	return func(artifact *github.Artifact) {
+		artifact.CreatedAt = &github.Timestamp{Time: createdAt}
+	}
+}
+
+// withRun lists the artifact along with the id, branch and head SHA of run
+func withRun(run *github.WorkflowRun) artifactOption {
+	return func(artifact *github.Artifact) {
+		artifact.WorkflowRun = &github.ArtifactWorkflowRun{ID: run.ID, HeadBranch: run.HeadBranch, HeadSHA: run.HeadSHA}
+	}
+}
+
 func TestFilterArtifacts_MinBytes(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -0,0 +1,242 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// ArtifactDownloader is the subset of the GitHub Actions API used to find where to download the zip of an artifact.
+// It is satisfied by the Actions service of a *github.Client.
+type ArtifactDownloader interface {
+	DownloadArtifact(ctx context.Context, owner, repo string, artifactID int64, maxRedirects int) (*url.URL, *github.Response, error)
+}
+
+// WithArtifactDownloader uses a custom implementation of the artifact download API. An ArtifactService which also
+// implements ArtifactDownloader is used when this option is omitted.
+func WithArtifactDownloader(downloader ArtifactDownloader) Option {
+	return func(a *App) {
+		a.downloader = downloader
+	}
+}
+
+// ArchiveStore stores the backups of artifacts before they are deleted
+type ArchiveStore interface {
+	// Put stores size bytes read from body at key, a slash-separated path such as octo-org/octo-docs/123-dist.zip.
+	// Put must fail when body fails, so that an incomplete backup is never mistaken for a complete one.
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+}
+
+// WithArchiveStore backs up every artifact to store before deleting it. Artifacts which can't be backed up are
+// not deleted.
+func WithArchiveStore(store ArchiveStore) Option {
+	return func(a *App) {
+		a.archive = store
+	}
+}
+
+// DirStore archives artifacts in a directory of the local filesystem
+type DirStore struct {
+	Dir string
+}
+
+// Put writes the file at key beneath Dir. The file only appears once it is complete.
+func (s *DirStore) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	target := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(target), ".partial-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	written, err := io.Copy(f, &contextReader{ctx: ctx, r: body})
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if written != size {
+		return fmt.Errorf("wrote %d bytes of %s, expected %d", written, key, size)
+	}
+	return os.Rename(f.Name(), target)
+}
+
+// contextReader fails reads once ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// ArchiveRecord is the metadata of an archived artifact, stored alongside its zip
+type ArchiveRecord struct {
+	Owner         string     `json:"owner"`
+	Repo          string     `json:"repo"`
+	ID            int64      `json:"id"`
+	Name          string     `json:"name"`
+	SizeInBytes   int64      `json:"size_in_bytes"`
+	SHA256        string     `json:"sha256,omitempty"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Expired       bool       `json:"expired,omitempty"`
+	WorkflowRunID int64      `json:"workflow_run_id,omitempty"`
+	HeadBranch    string     `json:"head_branch,omitempty"`
+	HeadSHA       string     `json:"head_sha,omitempty"`
+	ArchivedAt    time.Time  `json:"archived_at"`
+}
+
+// errArtifactGone is returned when an artifact no longer exists to be archived
+var errArtifactGone = errors.New("artifact no longer exists")
+
+// archiveKey returns the key of the backup of an artifact, without an extension
+func (a *App) archiveKey(artifact *github.Artifact) string {
+	return path.Join(*a.Owner, *a.Repo, fileName(artifact))
+}
+
+// fileName names the files of an artifact by its id and name, without an extension. Characters of the name which
+// aren't safe in paths are replaced.
+func fileName(artifact *github.Artifact) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, artifact.GetName())
+	return fmt.Sprintf("%d-%s", artifact.GetID(), name)
+}
+
+// archiveArtifact backs up the zip of an artifact, followed by its metadata. The zip must be exactly as large as the
+// artifact. Expired artifacts no longer have a zip, so only their metadata is stored.
+func (a *App) archiveArtifact(ctx context.Context, gate *rateGate, artifact *github.Artifact) error {
+	ctx, cancel := within(ctx, a.Timeouts.Archive)
+	defer cancel()
+
+	key := a.archiveKey(artifact)
+	record := &ArchiveRecord{
+		Owner:         *a.Owner,
+		Repo:          *a.Repo,
+		ID:            artifact.GetID(),
+		Name:          artifact.GetName(),
+		SizeInBytes:   artifact.GetSizeInBytes(),
+		Expired:       artifact.GetExpired(),
+		WorkflowRunID: artifact.GetWorkflowRun().GetID(),
+		HeadBranch:    artifact.GetWorkflowRun().GetHeadBranch(),
+		HeadSHA:       artifact.GetWorkflowRun().GetHeadSHA(),
+	}
+	if artifact.CreatedAt != nil {
+		record.CreatedAt = &artifact.CreatedAt.Time
+	}
+	if artifact.ExpiresAt != nil {
+		record.ExpiresAt = &artifact.ExpiresAt.Time
+	}
+
+	if !artifact.GetExpired() {
+		sum, err := a.storeZip(ctx, gate, a.archive, artifact, key+".zip")
+		if err != nil {
+			return err
+		}
+		record.SHA256 = sum
+	}
+
+	record.ArchivedAt = time.Now().UTC()
+	metadata, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := a.archive.Put(ctx, key+".json", bytes.NewReader(metadata), int64(len(metadata))); err != nil {
+		return fmt.Errorf("unable to store metadata: %w", err)
+	}
+	a.log().WithFields(log.Fields{"id": artifact.GetID(), "name": artifact.GetName(), "key": key}).Info("Archived artifact")
+	return nil
+}
+
+// storeZip downloads the zip of an artifact into store, returning its SHA-256
+func (a *App) storeZip(ctx context.Context, gate *rateGate, store ArchiveStore, artifact *github.Artifact, key string) (string, error) {
+	requestContext, cancel := within(ctx, a.Timeouts.Request)
+	var location *url.URL
+	resp, err := a.withRetry(requestContext, gate, func(ctx context.Context) (resp *github.Response, err error) {
+		location, resp, err = a.downloader.DownloadArtifact(ctx, *a.Owner, *a.Repo, artifact.GetID(), 1)
+		return resp, err
+	})
+	cancel()
+	if err != nil && statusCode(resp) == http.StatusNotFound {
+		return "", errArtifactGone
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to find download: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	download, err := a.downloads.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to download: %w", err)
+	}
+	defer func() { _ = download.Body.Close() }()
+	if download.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to download: %s", download.Status)
+	}
+	size := artifact.GetSizeInBytes()
+	if download.ContentLength >= 0 && download.ContentLength != size {
+		return "", fmt.Errorf("download is %d bytes, expected %d", download.ContentLength, size)
+	}
+
+	body := &sizedReader{r: download.Body, remaining: size, hash: sha256.New()}
+	if err := store.Put(ctx, key, body, size); err != nil {
+		return "", fmt.Errorf("unable to store zip: %w", err)
+	}
+	if body.remaining != 0 {
+		return "", fmt.Errorf("download is %d bytes, expected %d", size-body.remaining, size)
+	}
+	return hex.EncodeToString(body.hash.Sum(nil)), nil
+}
+
+// sizedReader hashes a body which must be exactly as long as expected, failing reads of a longer or shorter body
+type sizedReader struct {
+	r         io.Reader
+	remaining int64
+	hash      hash.Hash
+}
+
+func (r *sizedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.hash.Write(p[:n])
+	r.remaining -= int64(n)
+	switch {
+	case r.remaining < 0:
+		return n, errors.New("download is larger than the artifact")
+	case errors.Is(err, io.EOF) && r.remaining > 0:
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+	"github.com/jimschubert/delete-artifacts/fakes3"
+)
+
+func TestRun_ArchiveDir(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	expired := createServedArtifact(3, "logs", 10, 1)
+	expired.Expired = github.Ptr(true)
+	server.AddArtifacts("octo-org", "octo-docs",
+		createServedArtifact(1, "dist", 10, 1),
+		createServedArtifact(2, "truncated", 10, 1),
+		expired,
+		createServedArtifact(4, "unavailable", 10, 1),
+	)
+	server.SetArtifactContent("octo-org", "octo-docs", 2, []byte("short"))
+	server.InjectFailure(fakegithub.Failure{Method: http.MethodGet, Path: "/_blobs/octo-org/octo-docs/4", Status: http.StatusInternalServerError})
+
+	dir := t.TempDir()
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"), WithArchiveStore(&DirStore{Dir: dir}))
+	app.MinBytes = 0
+	report, err := app.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// only artifacts which were backed up are deleted
+	deleted := server.Deleted("octo-org", "octo-docs")
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i] < deleted[j] })
+	if len(deleted) != 2 || deleted[0] != 1 || deleted[1] != 3 {
+		t.Errorf("expected the archived artifacts to be deleted, got %v", deleted)
+	}
+	if report.Deleted != 2 || report.Failed != 2 {
+		t.Errorf("expected 2 deleted and 2 failed, got %+v", report)
+	}
+	for _, outcome := range report.Outcomes {
+		if outcome.Status == StatusFailed && !strings.HasPrefix(outcome.Err.Error(), "archive failed") {
+			t.Errorf("expected artifact %d to fail archiving, got %v", outcome.Artifact.GetID(), outcome.Err)
+		}
+	}
+
+	repoDir := filepath.Join(dir, "octo-org", "octo-docs")
+	zip, err := os.ReadFile(filepath.Join(repoDir, "1-dist.zip"))
+	if err != nil || string(zip) != "abcdefghij" {
+		t.Fatalf("expected the zip of artifact 1 to be archived, got %q (%v)", zip, err)
+	}
+	var record ArchiveRecord
+	metadata, _ := os.ReadFile(filepath.Join(repoDir, "1-dist.json"))
+	if err := json.Unmarshal(metadata, &record); err != nil {
+		t.Fatalf("expected the metadata of artifact 1 to be archived: %v", err)
+	}
+	sum := sha256.Sum256(zip)
+	if record.ID != 1 || record.Owner != "octo-org" || record.SizeInBytes != 10 || record.SHA256 != hex.EncodeToString(sum[:]) || record.WorkflowRunID != 1 {
+		t.Errorf("unexpected metadata of artifact 1: %+v", record)
+	}
+
+	if _, err := os.Stat(filepath.Join(repoDir, "3-logs.json")); err != nil {
+		t.Errorf("expected the metadata of the expired artifact to be archived: %v", err)
+	}
+	entries, _ := os.ReadDir(repoDir)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "1-dist.json,1-dist.zip,3-logs.json" {
+		t.Errorf("expected no partial backups, got %v", names)
+	}
+}
+
+func TestRun_ArchiveS3(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", createServedArtifact(1, "dist", 10, 1))
+	objects := fakes3.NewServer()
+	defer objects.Close()
+	objects.CreateBucket("backups")
+
+	store := &S3Store{Endpoint: objects.URL, Bucket: "backups", Prefix: "artifacts", AccessKeyID: fakes3.AccessKeyID, SecretAccessKey: fakes3.SecretAccessKey}
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"), WithArchiveStore(store))
+	app.MinBytes = 0
+	report, err := app.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Deleted != 1 {
+		t.Errorf("expected the archived artifact to be deleted, got %+v", report)
+	}
+	keys := objects.Keys("backups")
+	if strings.Join(keys, ",") != "artifacts/octo-org/octo-docs/1-dist.json,artifacts/octo-org/octo-docs/1-dist.zip" {
+		t.Errorf("expected the zip and metadata to be uploaded, got %v", keys)
+	}
+}
+
+func TestRun_ArchiveRequiresDownloader(t *testing.T) {
+	app, _ := NewWithOptions(WithArtifactService(&fakeArtifactService{}), WithRepository("octo-org", "octo-docs"),
+		WithLogger(quietLogger()), WithArchiveStore(&DirStore{Dir: t.TempDir()}))
+	if _, err := app.Run(context.Background()); err == nil {
+		t.Error("expected archiving without an ArtifactDownloader to fail")
+	}
+}
+
+func TestArchiveKey(t *testing.T) {
+	app := &App{Owner: github.Ptr("octo-org"), Repo: github.Ptr("octo-docs")}
+	artifact := createServedArtifact(7, "../coverage report:v1", 10, 1)
+	if got := app.archiveKey(artifact); got != "octo-org/octo-docs/7-.._coverage_report_v1" {
+		t.Errorf("expected the name to be made safe for paths, got %q", got)
+	}
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"sort"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// BudgetOrderOldest evicts the oldest artifacts first when enforcing a storage budget
+	BudgetOrderOldest = "oldest"
+	// BudgetOrderLargest evicts the largest artifacts first when enforcing a storage budget
+	BudgetOrderLargest = "largest"
+)
+
+// applyBudget selects artifacts from the candidates to evict until the total size of the listing is within Budget.
+// Candidates are those which passed every other filter, so the filters act as eligibility constraints.
+func (a *App) applyBudget(listed []*github.Artifact, candidates []*github.Artifact) []*github.Artifact {
+	if a.Budget == nil {
+		return candidates
+	}
+
+	var total int64
+	for _, artifact := range listed {
+		// expired artifacts no longer count toward storage
+		if !artifact.GetExpired() {
+			total += artifact.GetSizeInBytes()
+		}
+	}
+
+	eligible := make([]*github.Artifact, 0, len(candidates))
+	for _, artifact := range candidates {
+		if !artifact.GetExpired() {
+			eligible = append(eligible, artifact)
+		}
+	}
+
+	sort.SliceStable(eligible, func(i, j int) bool {
+		if a.BudgetOrder == BudgetOrderLargest {
+			return eligible[i].GetSizeInBytes() > eligible[j].GetSizeInBytes()
+		}
+		return eligible[i].GetCreatedAt().Before(eligible[j].GetCreatedAt().Time)
+	})
+
+	remaining := total
+	evicted := make([]*github.Artifact, 0)
+	for _, artifact := range eligible {
+		if remaining <= *a.Budget {
+			break
+		}
+		evicted = append(evicted, artifact)
+		remaining -= artifact.GetSizeInBytes()
+	}
+
+	fields := log.Fields{"budget": *a.Budget, "before": total, "after": remaining, "count": len(evicted), "order": a.budgetOrder()}
+	a.log().WithFields(fields).Debug("Storage budget selected artifacts to evict.")
+	if remaining > *a.Budget {
+		a.log().WithFields(fields).Warn("Storage budget can't be met by the artifacts eligible for deletion.")
+	}
+
+	return evicted
+}
+
+// logEvictions logs the artifacts a storage budget evicts, once Run is about to delete them or report them in a dry run
+func (a *App) logEvictions(evicted []*github.Artifact) {
+	if a.Budget == nil {
+		return
+	}
+	var bytes int64
+	for _, artifact := range evicted {
+		bytes += artifact.GetSizeInBytes()
+	}
+	fields := log.Fields{"budget": *a.Budget, "count": len(evicted), "bytes": bytes, "order": a.budgetOrder()}
+	if a.DryRun {
+		a.log().WithFields(fields).Warn("DryRun: storage budget would have evicted artifacts")
+	} else {
+		a.log().WithFields(fields).Info("Storage budget evicting artifacts")
+	}
+}
+
+func (a *App) budgetOrder() string {
+	if len(a.BudgetOrder) == 0 {
+		return BudgetOrderOldest
+	}
+	return a.BudgetOrder
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// Helper to create a test artifact with an id and size
+func createSizedArtifact(id int64, sizeInBytes int64, createdAt time.Time) *github.Artifact {
+	artifact := createArtifact("test-artifact", sizeInBytes, createdAt)
+	artifact.ID = &id
+	return artifact
+}
+
+func TestApplyBudget(t *testing.T) {
+	now := time.Now()
+	listed := []*github.Artifact{
+		createSizedArtifact(1, 300, now.Add(-4*time.Hour)),
+		createSizedArtifact(2, 100, now.Add(-3*time.Hour)),
+		createSizedArtifact(3, 500, now.Add(-2*time.Hour)),
+		createSizedArtifact(4, 100, now.Add(-1*time.Hour)),
+	}
+
+	tests := []struct {
+		name       string
+		budget     *int64
+		order      string
+		candidates []*github.Artifact
+		want       []int64
+	}{
+		{
+			name:       "no budget - candidates unchanged",
+			budget:     nil,
+			candidates: listed,
+			want:       []int64{1, 2, 3, 4},
+		},
+		{
+			name:       "already within budget - nothing evicted",
+			budget:     int64Ptr(1000),
+			candidates: listed,
+			want:       []int64{},
+		},
+		{
+			name:       "oldest first",
+			budget:     int64Ptr(650),
+			candidates: listed,
+			want:       []int64{1, 2},
+		},
+		{
+			name:       "largest first",
+			budget:     int64Ptr(650),
+			order:      BudgetOrderLargest,
+			candidates: listed,
+			want:       []int64{3},
+		},
+		{
+			name:       "filters constrain eligibility",
+			budget:     int64Ptr(650),
+			candidates: []*github.Artifact{listed[2], listed[3]},
+			want:       []int64{3},
+		},
+		{
+			name:       "budget can't be met - evict everything eligible",
+			budget:     int64Ptr(0),
+			candidates: []*github.Artifact{listed[3]},
+			want:       []int64{4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{Budget: tt.budget, BudgetOrder: tt.order}
+			result := app.applyBudget(listed, tt.candidates)
+			if len(result) != len(tt.want) {
+				t.Fatalf("expected %d artifacts, got %d", len(tt.want), len(result))
+			}
+			for i, id := range tt.want {
+				if result[i].GetID() != id {
+					t.Errorf("expected artifact %d at position %d, got %d", id, i, result[i].GetID())
+				}
+			}
+		})
+	}
+}
+
+func TestApplyBudget_IgnoresExpired(t *testing.T) {
+	now := time.Now()
+	expired := createSizedArtifact(1, 1000, now.Add(-2*time.Hour))
+	expired.Expired = github.Ptr(true)
+	live := createSizedArtifact(2, 100, now.Add(-1*time.Hour))
+
+	app := &App{Budget: int64Ptr(100)}
+	result := app.applyBudget([]*github.Artifact{expired, live}, []*github.Artifact{expired, live})
+	if len(result) != 0 {
+		t.Errorf("expected nothing evicted when only expired artifacts exceed the budget, got %d", len(result))
+	}
+}
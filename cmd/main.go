@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	app "github.com/jimschubert/delete-artifacts"
 
@@ -17,20 +23,180 @@ var date = "1970-01-01T00:00:00Z"
 var commit = "unknown"
 var projectName = "delete-artifacts"
 
-var opts struct {
-	Owner          *string     `short:"o" help:"GitHub Owner/Org name" env:"GITHUB_ACTOR"`
-	Repo           *string     `short:"r" help:"GitHub Repo name" env:"GITHUB_REPO"`
-	RunId          *int64      `short:"i" name:"run-id" help:"The workflow run id from which to delete artifacts" optional:""`
-	MinBytes       int64       `name:"min" help:"Minimum size in bytes. Artifacts greater than this size will be deleted." default:"50000000"`
-	MaxBytes       *int64      `name:"max" help:"Maximum size in bytes. Artifacts less than this size will be deleted" optional:""`
-	Name           string      `short:"n" help:"Artifact name to be deleted" default:""`
-	Pattern        string      `short:"p" help:"Regex pattern (POSIX) for matching artifact name to be deleted" default:""`
-	ActiveDuration string      `short:"a" name:"active" help:"Consider artifacts as 'active' within this time frame, and avoid deletion. Duration formatted such as 23h59m." default:""`
-	LogLevel       string      `short:"l" name:"log-level" help:"Log level (trace, debug, info, warn, error, fatal, panic)" env:"LOG_LEVEL" default:"info"`
+const (
+	exitSuccess        = 0
+	exitPartialFailure = 2
+	exitNothingToDo    = 3
+	// exitCancelled follows the shell convention for a process ended by SIGINT
+	exitCancelled = 130
+)
+
+var cli struct {
+	List       listCmd     `cmd:"" help:"List the artifacts matching the filters, never deleting anything"`
+	Delete     deleteCmd   `cmd:"" default:"withargs" help:"Delete artifacts matching the filters (default command)"`
+	Plan       planCmd     `cmd:"" help:"Write the artifacts which would be deleted to a plan file for review, without deleting anything"`
+	Apply      applyCmd    `cmd:"" help:"Delete the artifacts of a plan file, after verifying each still exists and still matches"`
+	Stats      statsCmd    `cmd:"" help:"Report the storage used by artifacts, broken down by name, prefix, workflow, branch and age, and the savings of the filters"`
+	Download   downloadCmd `cmd:"" help:"Download the artifacts matching the filters into a directory, without deleting anything"`
+	VersionCmd versionCmd  `cmd:"" name:"version" help:"Display version information"`
+	LogLevel   string      `short:"l" name:"log-level" help:"Log level (trace, debug, info, warn, error, fatal, panic)" env:"LOG_LEVEL" default:"info"`
+	Version    VersionFlag `short:"v" help:"Display version information"`
+}
+
+type authFlags struct {
+	AppID             int64  `name:"app-id" help:"Authenticate as an installation of this GitHub App, rather than with GITHUB_TOKEN" env:"GITHUB_APP_ID" optional:""`
+	AppPrivateKey     string `name:"app-private-key" help:"PEM encoded private key of the GitHub App" env:"GITHUB_APP_PRIVATE_KEY" optional:""`
+	AppPrivateKeyFile string `name:"app-private-key-file" help:"Path to the PEM encoded private key of the GitHub App" env:"GITHUB_APP_PRIVATE_KEY_FILE" type:"existingfile" optional:""`
+	AppInstallation   int64  `name:"app-installation-id" help:"Installation id of the GitHub App, which is looked up for the owner (or org) when omitted" env:"GITHUB_APP_INSTALLATION_ID" optional:""`
+}
+
+type endpointFlags struct {
+	APIURL         string `name:"api-url" help:"Base URL of the GitHub API, such as https://github.example.com/api/v3 for GitHub Enterprise Server" env:"GITHUB_API_URL" optional:""`
+	UploadURL      string `name:"upload-url" help:"Base URL for uploads, derived from --api-url when omitted" env:"GITHUB_UPLOAD_URL" optional:""`
+	CAFile         string `name:"ca-file" help:"Path to a PEM bundle of certificate authorities to trust in addition to the system's" env:"GITHUB_CA_FILE" type:"existingfile" optional:""`
+	ClientCertFile string `name:"client-cert" help:"Path to a PEM client certificate presented to the server" env:"GITHUB_CLIENT_CERT" type:"existingfile" optional:""`
+	ClientKeyFile  string `name:"client-key" help:"Path to the PEM key of --client-cert" env:"GITHUB_CLIENT_KEY" type:"existingfile" optional:""`
+	Proxy          string `name:"proxy" help:"URL of the proxy for every request, rather than HTTPS_PROXY/HTTP_PROXY" optional:""`
+}
+
+// clientFlags configure how the GitHub API is reached
+type clientFlags struct {
+	Auth     authFlags     `embed:""`
+	Endpoint endpointFlags `embed:""`
+	Retry    retryFlags    `embed:""`
+	Timeout  timeoutFlags  `embed:""`
+}
+
+type repoFlags struct {
+	Owner *string `short:"o" help:"GitHub Owner/Org name" env:"GITHUB_ACTOR"`
+	Repo  *string `short:"r" help:"GitHub Repo name" env:"GITHUB_REPO"`
+}
+
+type filterFlags struct {
+	RunId          *int64        `short:"i" name:"run-id" help:"The workflow run id from which to delete artifacts" optional:""`
+	MinBytes       int64         `name:"min" help:"Minimum size in bytes. Artifacts greater than this size will be deleted." default:"50000000"`
+	MaxBytes       *int64        `name:"max" help:"Maximum size in bytes. Artifacts less than this size will be deleted" optional:""`
+	Name           string        `short:"n" help:"Artifact name to be deleted" default:""`
+	Pattern        string        `short:"p" help:"Regex pattern (POSIX) for matching artifact name to be deleted" default:""`
+	ActiveDuration string        `short:"a" name:"active" help:"Consider artifacts as 'active' within this time frame, and avoid deletion. Duration formatted such as 23h59m." default:""`
+	Policy         string        `name:"policy" help:"Path to a YAML retention policy file. When set, its rules replace the min, max, name, pattern and active filters." type:"existingfile" optional:""`
+	KeepLast       int           `name:"keep-last" help:"Keep the newest N artifacts of each name, deleting only older ones which match the other filters" default:"0"`
+	KeepLastBranch bool          `name:"keep-last-by-branch" help:"Group --keep-last by artifact name and the branch of the producing workflow run"`
+	Budget         *int64        `name:"budget" help:"Storage budget in bytes. Artifacts matching the other filters are evicted until total storage is within the budget." optional:""`
+	BudgetOrder    string        `name:"budget-order" help:"Order in which --budget evicts artifacts (oldest, largest)" enum:"oldest,largest" default:"oldest"`
+	Workflow       string        `name:"workflow" help:"Only delete artifacts of runs of this workflow, by file (ci.yml) or name" default:""`
+	Branch         string        `name:"branch" help:"Only delete artifacts of runs on branches matching this glob, where * also matches /" default:""`
+	HeadSHA        string        `name:"head-sha" help:"Only delete artifacts of runs of this commit, or of commits with this SHA prefix" default:""`
+	Event          string        `name:"event" help:"Only delete artifacts of runs triggered by this event, such as push or pull_request" default:""`
+	Conclusion     string        `name:"conclusion" help:"Only delete artifacts of runs with this conclusion, such as success or failure" default:""`
+	ClosedPRs      bool          `name:"closed-prs" help:"Only delete artifacts of pull request runs whose pull requests are all closed or merged"`
+	ClosedPRGrace  time.Duration `name:"closed-pr-grace" help:"With --closed-prs, keep artifacts until their pull requests have been closed this long, such as 72h" default:"0s"`
+	PageParallel   int           `name:"page-concurrency" help:"Number of pages of artifacts listed concurrently" default:"4"`
+	Protect        []string      `name:"protect" help:"Never delete artifacts matching this rule, even with --min=0 (name:<glob>, pattern:<regex>, run:<id>, branch:<glob> or tagged). Repeatable." sep:"none" optional:""`
+}
+
+type orgFlags struct {
+	Org          string `name:"org" help:"Sweep every repository of this GitHub Org (or user) instead of a single repo" default:""`
+	RepoPattern  string `name:"repo-pattern" help:"Regex pattern (POSIX) for matching repository names to sweep with --org" default:""`
+	Topic        string `name:"topic" help:"Only sweep repositories with this topic with --org" default:""`
+	Visibility   string `name:"visibility" help:"Only sweep repositories with this visibility with --org (all, public, private, internal)" enum:"all,public,private,internal" default:"all"`
+	Archived     bool   `name:"include-archived" help:"Include archived repositories with --org"`
+	RepoParallel int    `name:"repo-concurrency" help:"Number of repositories swept concurrently with --org" default:"4"`
+}
+
+type retryFlags struct {
+	Retries       int           `name:"retries" help:"Maximum attempts of each API call failing with a server error, 429 or rate limit" default:"3"`
+	RetryDelay    time.Duration `name:"retry-delay" help:"Delay before the first retry, doubling on each subsequent retry" default:"1s"`
+	RetryMaxDelay time.Duration `name:"retry-max-delay" help:"Maximum delay between retries" default:"30s"`
+	RetryJitter   float64       `name:"retry-jitter" help:"Random fraction of the delay added to each retry" default:"0.2"`
+}
+
+type timeoutFlags struct {
+	Timeout        time.Duration `name:"timeout" help:"Maximum duration of a run of each repository. 0 disables the limit." default:"2m"`
+	RequestTimeout time.Duration `name:"request-timeout" help:"Maximum duration of each list or get call, including retries. 0 disables the limit." default:"30s"`
+	DeleteTimeout  time.Duration `name:"delete-timeout" help:"Maximum duration of each delete request, also allowed after an interrupt. 0 disables the limit." default:"30s"`
+	ArchiveTimeout time.Duration `name:"archive-timeout" help:"Maximum duration of the backup of each artifact with --archive-dir, or of each download. 0 disables the limit." default:"10m"`
+}
+
+// outputFlags configure how the report of a command is written and mapped to the exit code
+type outputFlags struct {
+	DetailedExit bool   `name:"detailed-exit-code" help:"Exit with 3 when no artifacts matched, rather than 0"`
+	Output       string `name:"output" help:"Write the matched artifacts and their outcomes to stdout (json, ndjson, csv, table)" enum:",json,ndjson,csv,table" default:""`
+}
+
+type deletionFlags struct {
+	Concurrency    int         `name:"concurrency" help:"Number of artifacts deleted concurrently. All deletions pause while rate limited." default:"1"`
+	Output         outputFlags `embed:""`
 	DryRun         bool        `name:"dry-run" help:"Dry-run that does not perform deletions"`
-	Version        VersionFlag `short:"v" help:"Display version information"`
+	Interactive    bool        `name:"interactive" help:"Review the artifacts selected for deletion before deleting them, in a sortable table on a terminal or with a y/N prompt otherwise"`
+	MaxDelete      int         `name:"max-delete" help:"Abort without deleting anything when more than this many artifacts of a repository would be deleted. 0 disables the cap." default:"0"`
+	MaxDeleteBytes int64       `name:"max-delete-bytes" help:"Abort without deleting anything when more than this many bytes of a repository would be deleted. 0 disables the cap." default:"0"`
+	ArchiveDir     string      `name:"archive-dir" help:"Back up the zip and metadata of each artifact to this directory, or to s3://bucket/prefix, before deleting it. Artifacts which can't be backed up aren't deleted." default:""`
+	S3Endpoint     string      `name:"archive-s3-endpoint" help:"URL of the S3-compatible object store of an s3:// --archive-dir, such as MinIO. Defaults to Amazon S3 in --archive-s3-region." env:"AWS_ENDPOINT_URL_S3" default:""`
+	S3Region       string      `name:"archive-s3-region" help:"Region of the bucket of an s3:// --archive-dir" env:"AWS_REGION" default:"us-east-1"`
+	MaxOrder       string      `name:"max-delete-order" help:"Over --max-delete or --max-delete-bytes, abort, or delete only the oldest or largest artifacts up to the cap (abort, oldest, largest)" enum:"abort,oldest,largest" default:"abort"`
+}
+
+type listCmd struct {
+	Client       clientFlags `embed:""`
+	Repo         repoFlags   `embed:""`
+	Filters      filterFlags `embed:""`
+	Org          orgFlags    `embed:""`
+	Output       string      `name:"output" help:"Format of the listed artifacts written to stdout (json, ndjson, csv, table)" enum:"json,ndjson,csv,table" default:"table"`
+	DetailedExit bool        `name:"detailed-exit-code" help:"Exit with 3 when no artifacts matched, rather than 0"`
+}
+
+type deleteCmd struct {
+	Client   clientFlags   `embed:""`
+	Repo     repoFlags     `embed:""`
+	Filters  filterFlags   `embed:""`
+	Org      orgFlags      `embed:""`
+	Deletion deletionFlags `embed:""`
+}
+
+type planCmd struct {
+	Client  clientFlags `embed:""`
+	Repo    repoFlags   `embed:""`
+	Filters filterFlags `embed:""`
+	Out     string      `name:"out" help:"Path of the plan file to write, or - for stdout" default:"-"`
+	PlanKey string      `name:"plan-key" help:"Key with which to sign the plan (HMAC-SHA256)" env:"DELETE_ARTIFACTS_PLAN_KEY"`
+}
+
+type applyCmd struct {
+	Plan     string        `arg:"" help:"Path of the plan file written by plan" type:"existingfile"`
+	Client   clientFlags   `embed:""`
+	Deletion deletionFlags `embed:""`
+	PlanKey  string        `name:"plan-key" help:"Key with which the plan was signed (HMAC-SHA256)" env:"DELETE_ARTIFACTS_PLAN_KEY"`
+	Protect  []string      `name:"protect" help:"Never delete planned artifacts matching this rule, in addition to the rules of the plan. Repeatable." sep:"none" optional:""`
 }
 
+type statsCmd struct {
+	Client  clientFlags `embed:""`
+	Repo    repoFlags   `embed:""`
+	Filters filterFlags `embed:""`
+	Output  string      `name:"output" help:"Format of the stats written to stdout (json, table)" enum:"json,table" default:"table"`
+	Top     int         `name:"top" help:"Number of the largest groups of each breakdown shown in the table. 0 shows every group." default:"10"`
+	Runs    bool        `name:"runs" help:"Also break down by workflow, which looks up the workflow run of every artifact, one API request per run"`
+}
+
+type downloadCmd struct {
+	Client      clientFlags `embed:""`
+	Repo        repoFlags   `embed:""`
+	Filters     filterFlags `embed:""`
+	Dir         string      `name:"dir" help:"Directory to download the artifacts into, as <id>-<name>.zip" type:"path" default:"."`
+	Extract     bool        `name:"extract" help:"Also extract each zip into a directory named <id>-<name>"`
+	Concurrency int         `name:"concurrency" help:"Number of artifacts downloaded concurrently. All downloads pause while rate limited." default:"4"`
+	Output      outputFlags `embed:""`
+}
+
+// runContext is bound to every command, which runs with its context and sets the exit code of the process
+type runContext struct {
+	ctx      context.Context
+	exitCode int
+}
+
+type versionCmd struct{}
+
 type VersionFlag string
 
 func (v VersionFlag) Decode(ctx *kong.DecodeContext) error { return nil }
@@ -41,33 +207,444 @@ func (v VersionFlag) BeforeApply(app *kong.Kong, vars kong.Vars) error {
 	return nil
 }
 
+func versionString() string {
+	return fmt.Sprintf("%s (%s)[%s]", version, commit, date)
+}
+
 func main() {
-	ctx := kong.Parse(&opts,
+	ctx := kong.Parse(&cli,
 		kong.Name(projectName),
 		kong.Description("Delete GitHub Actions artifacts"),
 		kong.UsageOnError(),
 		kong.Vars{
-			"version": fmt.Sprintf("%s (%s)[%s]", version, commit, date),
+			"version": versionString(),
 		},
 	)
 
-	initLogging(opts.LogLevel)
-
-	application, err := app.New(
-		opts.Owner,
-		opts.Repo,
-		opts.RunId,
-		opts.MinBytes,
-		opts.MaxBytes,
-		opts.Name,
-		opts.Pattern,
-		opts.ActiveDuration,
-		opts.DryRun)
-	ctx.FatalIfErrorf(err, "unable to construct application with specific parameters.")
-	err = application.Run()
-	ctx.FatalIfErrorf(err, "execution failed.")
-
-	log.Info("Run complete.")
+	initLogging(cli.LogLevel)
+
+	// the first signal cancels the run, allowing in-flight deletions to finish; a second one exits immediately
+	runCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-runCtx.Done()
+		stop()
+		log.Warn("Interrupted, finishing in-flight requests. Interrupt again to exit immediately.")
+	}()
+
+	rc := &runContext{ctx: runCtx, exitCode: exitSuccess}
+	err := ctx.Run(rc)
+	ctx.FatalIfErrorf(err)
+	os.Exit(rc.exitCode)
+}
+
+func (c *listCmd) Run(rc *runContext) error {
+	owner := c.Org.Org
+	if len(owner) == 0 && c.Repo.Owner != nil {
+		owner = *c.Repo.Owner
+	}
+	application, err := newApplication(c.Client, owner)
+	if err != nil {
+		return fmt.Errorf("unable to construct application with specific parameters: %w", err)
+	}
+	c.Repo.apply(application)
+	if err := c.Filters.apply(application); err != nil {
+		return err
+	}
+	c.Org.apply(application)
+
+	// List never deletes, whichever flags are set
+	report, err := application.List(rc.ctx)
+	if err != nil && (report == nil || !errors.Is(err, context.Canceled)) {
+		return fmt.Errorf("listing failed: %w", err)
+	}
+
+	var bytes int64
+	for _, outcome := range report.Outcomes {
+		bytes += outcome.Artifact.GetSizeInBytes()
+	}
+	log.WithFields(log.Fields{"matched": report.Matched, "bytes": bytes}).Info("List complete.")
+	output := outputFlags{Output: c.Output, DetailedExit: c.DetailedExit}
+	return output.write(rc, report)
+}
+
+func (c *versionCmd) Run(rc *runContext) error {
+	fmt.Println(versionString())
+	return nil
+}
+
+func (c *deleteCmd) Run(rc *runContext) error {
+	owner := c.Org.Org
+	if len(owner) == 0 && c.Repo.Owner != nil {
+		owner = *c.Repo.Owner
+	}
+	application, err := newApplication(c.Client, owner)
+	if err != nil {
+		return fmt.Errorf("unable to construct application with specific parameters: %w", err)
+	}
+	c.Repo.apply(application)
+	if err := c.Filters.apply(application); err != nil {
+		return err
+	}
+	c.Org.apply(application)
+	if err := c.Deletion.apply(application); err != nil {
+		return err
+	}
+
+	report, err := application.Run(rc.ctx)
+	if err != nil && (report == nil || !errors.Is(err, context.Canceled)) {
+		// artifacts are deleted while listing, so those deleted before a listing failure, or skipped over the deletion cap,
+		// are still reported
+		if report != nil && len(report.Outcomes) > 0 {
+			_ = c.Deletion.finish(rc, report)
+		}
+		return fmt.Errorf("execution failed: %w", err)
+	}
+	return c.Deletion.finish(rc, report)
+}
+
+func (c *planCmd) Run(rc *runContext) error {
+	owner := ""
+	if c.Repo.Owner != nil {
+		owner = *c.Repo.Owner
+	}
+	application, err := newApplication(c.Client, owner)
+	if err != nil {
+		return fmt.Errorf("unable to construct application with specific parameters: %w", err)
+	}
+	c.Repo.apply(application)
+	if err := c.Filters.apply(application); err != nil {
+		return err
+	}
+	application.PlanKey = []byte(c.PlanKey)
+
+	plan, err := application.Plan(rc.ctx)
+	if err != nil {
+		return fmt.Errorf("planning failed: %w", err)
+	}
+
+	out := os.Stdout
+	if c.Out != "-" {
+		out, err = os.Create(c.Out)
+		if err != nil {
+			return fmt.Errorf("unable to create plan file: %w", err)
+		}
+		defer func() { _ = out.Close() }()
+	}
+	if err := app.WritePlan(out, plan); err != nil {
+		return fmt.Errorf("unable to write plan: %w", err)
+	}
+
+	var bytes int64
+	for _, artifact := range plan.Artifacts {
+		bytes += artifact.SizeInBytes
+	}
+	log.WithFields(log.Fields{"count": len(plan.Artifacts), "bytes": bytes, "signed": len(plan.Signature) > 0, "out": c.Out}).
+		Info("Plan written.")
+	return nil
+}
+
+func (c *applyCmd) Run(rc *runContext) error {
+	f, err := os.Open(c.Plan)
+	if err != nil {
+		return fmt.Errorf("unable to open plan: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	plan, err := app.ReadPlan(f)
+	if err != nil {
+		return err
+	}
+
+	application, err := newApplication(c.Client, plan.Owner)
+	if err != nil {
+		return fmt.Errorf("unable to construct application with specific parameters: %w", err)
+	}
+	if err := c.Deletion.apply(application); err != nil {
+		return err
+	}
+	application.PlanKey = []byte(c.PlanKey)
+	application.Protect = c.Protect
+
+	report, err := application.Apply(rc.ctx, plan)
+	if err != nil && (report == nil || !errors.Is(err, context.Canceled)) {
+		if report != nil && len(report.Outcomes) > 0 {
+			_ = c.Deletion.finish(rc, report)
+		}
+		return fmt.Errorf("unable to apply plan: %w", err)
+	}
+	return c.Deletion.finish(rc, report)
+}
+
+func (c *statsCmd) Run(rc *runContext) error {
+	owner := ""
+	if c.Repo.Owner != nil {
+		owner = *c.Repo.Owner
+	}
+	application, err := newApplication(c.Client, owner)
+	if err != nil {
+		return fmt.Errorf("unable to construct application with specific parameters: %w", err)
+	}
+	c.Repo.apply(application)
+	if err := c.Filters.apply(application); err != nil {
+		return err
+	}
+	application.StatsRuns = c.Runs
+
+	stats, err := application.Stats(rc.ctx)
+	if err != nil {
+		return fmt.Errorf("unable to collect stats: %w", err)
+	}
+	log.WithFields(log.Fields{"count": stats.Total.Count, "bytes": stats.Total.Bytes, "savings": stats.Total.Savings}).
+		Info("Stats collected.")
+	if err := app.WriteStats(os.Stdout, c.Output, stats, c.Top); err != nil {
+		return fmt.Errorf("unable to write stats: %w", err)
+	}
+	return nil
+}
+
+func (c *downloadCmd) Run(rc *runContext) error {
+	owner := ""
+	if c.Repo.Owner != nil {
+		owner = *c.Repo.Owner
+	}
+	application, err := newApplication(c.Client, owner)
+	if err != nil {
+		return fmt.Errorf("unable to construct application with specific parameters: %w", err)
+	}
+	c.Repo.apply(application)
+	if err := c.Filters.apply(application); err != nil {
+		return err
+	}
+	application.Extract = c.Extract
+	application.Concurrency = c.Concurrency
+
+	report, err := application.Download(rc.ctx, c.Dir)
+	if err != nil && (report == nil || !errors.Is(err, context.Canceled)) {
+		return fmt.Errorf("download failed: %w", err)
+	}
+
+	summary := log.WithFields(log.Fields{
+		"matched":    report.Matched,
+		"downloaded": report.Downloaded,
+		"failed":     report.Failed,
+		"skipped":    report.Skipped,
+		"dir":        c.Dir,
+	})
+	if report.Result() == app.ResultCancelled {
+		summary.Warn("Download cancelled, artifacts not yet downloaded are reported as skipped.")
+	} else {
+		summary.Info("Download complete.")
+	}
+	for _, outcome := range report.Outcomes {
+		if outcome.Status == app.StatusFailed {
+			log.WithFields(log.Fields{"repo": outcome.Owner + "/" + outcome.Repo, "name": outcome.Artifact.GetName(), "id": outcome.Artifact.GetID()}).
+				WithError(outcome.Err).Error("Failed to download artifact")
+		}
+	}
+	return c.Output.write(rc, report)
+}
+
+// newApplication creates the application, authenticating as a GitHub App installation of owner when configured
+func newApplication(client clientFlags, owner string) (*app.App, error) {
+	retry := client.Retry
+	options := []app.Option{
+		app.WithRetryPolicy(app.RetryPolicy{
+			MaxAttempts: retry.Retries,
+			BaseDelay:   retry.RetryDelay,
+			MaxDelay:    retry.RetryMaxDelay,
+			Jitter:      retry.RetryJitter,
+		}),
+		app.WithTimeouts(app.Timeouts{
+			Run:     client.Timeout.Timeout,
+			Request: client.Timeout.RequestTimeout,
+			Delete:  client.Timeout.DeleteTimeout,
+			Archive: client.Timeout.ArchiveTimeout,
+		}),
+		app.WithEndpoint(app.Endpoint{
+			APIURL:         client.Endpoint.APIURL,
+			UploadURL:      client.Endpoint.UploadURL,
+			CAFile:         client.Endpoint.CAFile,
+			ClientCertFile: client.Endpoint.ClientCertFile,
+			ClientKeyFile:  client.Endpoint.ClientKeyFile,
+			ProxyURL:       client.Endpoint.Proxy,
+		}),
+	}
+	if client.Auth.AppID > 0 {
+		githubApp, err := client.Auth.githubApp(owner)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, app.WithGitHubApp(githubApp))
+	}
+	return app.NewWithOptions(options...)
+}
+
+func (f *authFlags) githubApp(owner string) (app.GitHubApp, error) {
+	key := []byte(f.AppPrivateKey)
+	if len(f.AppPrivateKeyFile) > 0 {
+		b, err := os.ReadFile(f.AppPrivateKeyFile)
+		if err != nil {
+			return app.GitHubApp{}, fmt.Errorf("unable to read github app private key: %w", err)
+		}
+		key = b
+	}
+	if len(key) == 0 {
+		return app.GitHubApp{}, errors.New("github app private key is missing, set --app-private-key or --app-private-key-file")
+	}
+	return app.GitHubApp{AppID: f.AppID, PrivateKey: key, InstallationID: f.AppInstallation, Owner: owner}, nil
+}
+
+func (f *repoFlags) apply(application *app.App) {
+	application.Owner = f.Owner
+	application.Repo = f.Repo
+}
+
+func (f *filterFlags) apply(application *app.App) error {
+	application.RunId = f.RunId
+	application.MinBytes = f.MinBytes
+	application.MaxBytes = f.MaxBytes
+	application.Name = f.Name
+	application.Pattern = f.Pattern
+	application.ActiveDuration = f.ActiveDuration
+	application.KeepLast = f.KeepLast
+	application.KeepLastByBranch = f.KeepLastBranch
+	application.Budget = f.Budget
+	application.BudgetOrder = f.BudgetOrder
+	application.Workflow = f.Workflow
+	application.Branch = f.Branch
+	application.HeadSHA = f.HeadSHA
+	application.Event = f.Event
+	application.Conclusion = f.Conclusion
+	application.ClosedPRs = f.ClosedPRs
+	application.ClosedPRGrace = f.ClosedPRGrace
+	application.PageConcurrency = f.PageParallel
+	application.Protect = f.Protect
+	if len(f.Policy) > 0 {
+		policy, err := app.LoadPolicy(f.Policy)
+		if err != nil {
+			return fmt.Errorf("unable to load policy: %w", err)
+		}
+		application.Policy = policy
+	}
+	return nil
+}
+
+func (f *orgFlags) apply(application *app.App) {
+	application.Org = f.Org
+	application.RepoPattern = f.RepoPattern
+	application.Topic = f.Topic
+	application.Visibility = f.Visibility
+	application.IncludeArchived = f.Archived
+	application.RepoConcurrency = f.RepoParallel
+}
+
+func (f *deletionFlags) apply(application *app.App) error {
+	application.Concurrency = f.Concurrency
+	application.DryRun = f.DryRun
+	application.MaxDelete = f.MaxDelete
+	application.MaxDeleteBytes = f.MaxDeleteBytes
+	application.MaxDeleteOrder = f.MaxOrder
+	if f.Interactive {
+		// without a terminal, the prompt is written to stderr so that stdout only holds the output
+		selector := app.NewPromptSelector(os.Stdin, os.Stderr, false)
+		if isTerminal(os.Stdout) {
+			selector = app.NewPromptSelector(os.Stdin, os.Stdout, true)
+		}
+		app.WithSelector(selector)(application)
+	}
+	if len(f.ArchiveDir) > 0 {
+		store, err := f.archiveStore()
+		if err != nil {
+			return err
+		}
+		app.WithArchiveStore(store)(application)
+	}
+	return nil
+}
+
+// archiveStore returns the store of --archive-dir, which is a bucket of an S3-compatible object store for s3:// URLs,
+// authenticating with the standard AWS environment variables
+func (f *deletionFlags) archiveStore() (app.ArchiveStore, error) {
+	location, isS3 := strings.CutPrefix(f.ArchiveDir, "s3://")
+	if !isS3 {
+		return &app.DirStore{Dir: f.ArchiveDir}, nil
+	}
+	bucket, prefix, _ := strings.Cut(location, "/")
+	if len(bucket) == 0 {
+		return nil, errors.New("archive-dir s3:// URL is missing a bucket")
+	}
+	return &app.S3Store{
+		Endpoint:        f.S3Endpoint,
+		Region:          f.S3Region,
+		Bucket:          bucket,
+		Prefix:          prefix,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}, nil
+}
+
+// isTerminal is true when f is a terminal able to show the interactive table
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
+
+// finish logs the report, which is partial when the run was cancelled, writes it in the requested output format, and sets the exit code from its result
+func (f *deletionFlags) finish(rc *runContext, report *app.Report) error {
+	summary := log.WithFields(log.Fields{
+		"matched": report.Matched,
+		"deleted": report.Deleted,
+		"failed":  report.Failed,
+		"skipped": report.Skipped,
+		"bytes":   report.BytesReclaimed,
+	})
+	if report.Result() == app.ResultCancelled {
+		summary.Warn("Run cancelled, artifacts not yet deleted are reported as skipped.")
+	} else {
+		summary.Info("Run complete.")
+	}
+	for _, outcome := range report.Outcomes {
+		if outcome.Status == app.StatusFailed {
+			log.WithFields(log.Fields{"repo": outcome.Owner + "/" + outcome.Repo, "name": outcome.Artifact.GetName(), "id": outcome.Artifact.GetID()}).
+				WithError(outcome.Err).Error("Failed to delete artifact")
+		}
+	}
+
+	return f.Output.write(rc, report)
+}
+
+// write writes the report in the requested output format, and sets the exit code from its result
+func (f *outputFlags) write(rc *runContext, report *app.Report) error {
+	if len(f.Output) > 0 {
+		if err := app.WriteReport(os.Stdout, f.Output, report); err != nil {
+			return fmt.Errorf("unable to write output: %w", err)
+		}
+	}
+
+	rc.exitCode = exitCode(report.Result(), f.DetailedExit)
+	return nil
+}
+
+// exitCode maps the result of a run to the process exit code. Without detailed exit codes, finding nothing to
+// delete is a success, as it was before exit codes were distinguished, so that scheduled runs which find nothing
+// don't fail.
+func exitCode(result app.Result, detailed bool) int {
+	switch result {
+	case app.ResultPartialFailure:
+		return exitPartialFailure
+	case app.ResultCancelled:
+		return exitCancelled
+	case app.ResultNothingToDo:
+		if detailed {
+			return exitNothingToDo
+		}
+		return exitSuccess
+	default:
+		return exitSuccess
+	}
 }
 
 func initLogging(level string) {
@@ -0,0 +1,195 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// reasonCancelled is reported for artifacts not deleted because the run was cancelled
+	reasonCancelled = "cancelled"
+	// maxRateLimitWaits bounds how many times a single deletion waits out a rate limit before it is considered failed
+	maxRateLimitWaits = 5
+	// defaultSecondaryRateLimitWait is used when a secondary rate limit response has no Retry-After header
+	defaultSecondaryRateLimitWait = time.Minute
+)
+
+// rateGate pauses every worker of a pool until a rate limit resets
+type rateGate struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// pause holds the gate closed until the given time, unless it is already closed for longer
+func (g *rateGate) pause(until time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if until.After(g.until) {
+		g.until = until
+	}
+}
+
+// wait blocks until the gate is open or the context is done
+func (g *rateGate) wait(ctx context.Context) error {
+	for {
+		g.mu.Lock()
+		delay := time.Until(g.until)
+		g.mu.Unlock()
+		if delay <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// deleteArtifacts deletes artifacts with Concurrency workers, pausing all workers whenever the API reports a rate limit.
+// Once ctx is done, no further deletions start and the remaining artifacts are reported as skipped.
+func (a *App) deleteArtifacts(ctx context.Context, artifacts []*github.Artifact, report *Report) {
+	pool := a.startDeleters(ctx, report)
+	pool.submit(ctx, artifacts)
+	pool.close()
+}
+
+// deleters is a pool of workers deleting the artifacts submitted to it, recording each outcome in report
+type deleters struct {
+	app    *App
+	report *Report
+	jobs   chan *github.Artifact
+	wg     sync.WaitGroup
+}
+
+// startDeleters starts Concurrency workers, which share a rate gate so that a rate limit pauses all of them
+func (a *App) startDeleters(ctx context.Context, report *Report) *deleters {
+	concurrency := a.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	gate := &rateGate{}
+	pool := &deleters{app: a, report: report, jobs: make(chan *github.Artifact)}
+	for i := 0; i < concurrency; i++ {
+		pool.wg.Add(1)
+		go func() {
+			defer pool.wg.Done()
+			for artifact := range pool.jobs {
+				if ctx.Err() != nil {
+					report.skipped(artifact, reasonCancelled)
+					continue
+				}
+				if a.archive != nil {
+					err := a.archiveArtifact(ctx, gate, artifact)
+					switch {
+					case errors.Is(err, errArtifactGone):
+						report.skipped(artifact, "no longer exists")
+						continue
+					case err != nil && ctx.Err() != nil:
+						report.skipped(artifact, reasonCancelled)
+						continue
+					case err != nil:
+						a.log().WithError(err).Warnf("Error archiving %s (artifact ID %d), not deleting it…", artifact.GetName(), artifact.GetID())
+						report.failed(artifact, fmt.Errorf("archive failed: %w", err))
+						continue
+					}
+				}
+				if err := a.deleteArtifact(ctx, gate, artifact); err != nil {
+					a.log().WithError(err).Warnf("Error deleting %s (artifact ID %d), ignoring…", artifact.GetName(), artifact.GetID())
+					report.failed(artifact, err)
+				} else {
+					report.deleted(artifact)
+				}
+			}
+		}()
+	}
+	return pool
+}
+
+// submit hands artifacts to the workers. Once ctx is done, the artifacts not yet handed over are reported as skipped.
+func (p *deleters) submit(ctx context.Context, artifacts []*github.Artifact) {
+	for i, artifact := range artifacts {
+		select {
+		case p.jobs <- artifact:
+		case <-ctx.Done():
+			p.app.log().WithFields(log.Fields{"remaining": len(artifacts) - i}).Warn("Cancelled, waiting for in-flight deletions to finish.")
+			for _, remaining := range artifacts[i:] {
+				p.report.skipped(remaining, reasonCancelled)
+			}
+			return
+		}
+	}
+}
+
+// close stops the workers once the submitted artifacts are deleted
+func (p *deleters) close() {
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+// deleteArtifact deletes a single artifact according to the retry policy. An artifact which no longer exists is
+// already gone, so it is considered deleted.
+func (a *App) deleteArtifact(ctx context.Context, gate *rateGate, artifact *github.Artifact) error {
+	fields := log.Fields{"size": artifact.GetSizeInBytes(), "name": artifact.GetName()}
+	resp, err := a.withRetry(ctx, gate, func(ctx context.Context) (*github.Response, error) {
+		a.log().WithFields(fields).Info("Deleting artifact")
+		// a request which has started is allowed to finish, so that the outcome of the artifact is known
+		requestContext, cancel := within(context.WithoutCancel(ctx), a.Timeouts.Delete)
+		defer cancel()
+		return a.artifacts.DeleteArtifact(requestContext, *a.Owner, *a.Repo, artifact.GetID())
+	})
+	if err != nil && statusCode(resp) == http.StatusNotFound {
+		a.log().WithFields(fields).Debug("Artifact was already deleted.")
+		return nil
+	}
+	return err
+}
+
+// rateLimitedUntil reports whether a response exhausted a rate limit, and when requests may resume
+func rateLimitedUntil(resp *github.Response, err error) (time.Time, bool) {
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return rateLimitErr.Rate.Reset.Time, true
+	}
+
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		wait := defaultSecondaryRateLimitWait
+		if abuseErr.RetryAfter != nil {
+			wait = *abuseErr.RetryAfter
+		}
+		return time.Now().Add(wait), true
+	}
+
+	// a limit of zero means the response carried no rate limit headers
+	if resp != nil && resp.Rate.Limit > 0 && resp.Rate.Remaining == 0 {
+		return resp.Rate.Reset.Time, true
+	}
+
+	return time.Time{}, false
+}
+
+// statusCode returns the HTTP status of a response, or zero when there was no response
+func statusCode(resp *github.Response) int {
+	if resp == nil || resp.Response == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+func isRateLimitError(err error) bool {
+	var rateLimitErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	return errors.As(err, &rateLimitErr) || errors.As(err, &abuseErr)
+}
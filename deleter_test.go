@@ -0,0 +1,223 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+func TestDeleteArtifacts_Concurrency(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	artifacts := make([]*github.Artifact, 0)
+	for i := int64(1); i <= 20; i++ {
+		artifacts = append(artifacts, createServedArtifact(i, "a", 100, 1))
+	}
+	server.AddArtifacts("octo-org", "octo-docs", artifacts...)
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+	app.Concurrency = 4
+
+	report := newReport("octo-org", "octo-docs")
+	app.deleteArtifacts(context.Background(), artifacts, report)
+
+	if report.Deleted != 20 || report.Failed != 0 || report.BytesReclaimed != 2000 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if len(server.Artifacts("octo-org", "octo-docs")) != 0 {
+		t.Errorf("expected every artifact to be deleted")
+	}
+}
+
+func TestDeleteArtifacts_SecondaryRateLimit(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	artifacts := []*github.Artifact{createServedArtifact(1, "a", 100, 1), createServedArtifact(2, "b", 100, 1)}
+	server.AddArtifacts("octo-org", "octo-docs", artifacts...)
+	server.InjectFailure(fakegithub.SecondaryRateLimit(http.MethodDelete, "/repos/octo-org/octo-docs/actions/artifacts/1", time.Second, 1))
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+	app.Concurrency = 2
+
+	started := time.Now()
+	report := newReport("octo-org", "octo-docs")
+	app.deleteArtifacts(context.Background(), artifacts, report)
+
+	if report.Deleted != 2 || report.Failed != 0 {
+		t.Errorf("expected the rate limited deletion to be retried, got %+v", report)
+	}
+	if elapsed := time.Since(started); elapsed < time.Second {
+		t.Errorf("expected deletions to pause for Retry-After, took %s", elapsed)
+	}
+}
+
+func TestDeleteArtifacts_PrimaryRateLimit(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	artifacts := []*github.Artifact{createServedArtifact(1, "a", 100, 1), createServedArtifact(2, "b", 100, 1)}
+	server.AddArtifacts("octo-org", "octo-docs", artifacts...)
+	server.SetRateLimit(100, 1, time.Now().Add(2*time.Second))
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+
+	report := newReport("octo-org", "octo-docs")
+	app.deleteArtifacts(context.Background(), artifacts, report)
+
+	if report.Deleted != 2 || report.Failed != 0 {
+		t.Errorf("expected deletions to resume once the rate limit reset, got %+v", report)
+	}
+}
+
+func TestDeleteArtifacts_Failure(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	artifacts := []*github.Artifact{createServedArtifact(1, "a", 100, 1), createServedArtifact(2, "b", 100, 1)}
+	server.AddArtifacts("octo-org", "octo-docs", artifacts...)
+	server.InjectFailure(fakegithub.Failure{Method: http.MethodDelete, Path: "/repos/octo-org/octo-docs/actions/artifacts/2", Status: http.StatusBadRequest})
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+	app.Concurrency = 2
+
+	report := newReport("octo-org", "octo-docs")
+	app.deleteArtifacts(context.Background(), artifacts, report)
+
+	if report.Deleted != 1 || report.Failed != 1 {
+		t.Errorf("expected the batch to continue past a failure, got %+v", report)
+	}
+}
+
+// cancellingService cancels the run once it has deleted the artifact with id cancelAfter
+type cancellingService struct {
+	*fakeArtifactService
+	cancelAfter int64
+	cancel      context.CancelFunc
+}
+
+func (c *cancellingService) DeleteArtifact(ctx context.Context, owner, repo string, artifactID int64) (*github.Response, error) {
+	if artifactID == c.cancelAfter {
+		c.cancel()
+	}
+	return c.fakeArtifactService.DeleteArtifact(ctx, owner, repo, artifactID)
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	artifacts := make([]*github.Artifact, 0)
+	for i := int64(1); i <= 5; i++ {
+		artifacts = append(artifacts, createServedArtifact(i, "a", 100, 1))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	service := &cancellingService{fakeArtifactService: &fakeArtifactService{artifacts: artifacts, perPage: 10}, cancelAfter: 2, cancel: cancel}
+	app, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+
+	report, err := app.Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to be cancelled, got %v", err)
+	}
+	// the deletion in flight when cancelled finishes, and no further deletions start
+	if len(service.deleted) != 2 || report.Deleted != 2 || report.Skipped != 3 || report.Result() != ResultCancelled {
+		t.Errorf("expected 2 deleted and 3 skipped, got deleted %v and %+v", service.deleted, report)
+	}
+	for _, outcome := range report.Outcomes {
+		if outcome.Status == StatusSkipped && outcome.Reason != reasonCancelled {
+			t.Errorf("expected skipped artifacts to be reported as cancelled, got %q", outcome.Reason)
+		}
+	}
+}
+
+func TestRun_CancelledBeforeListing(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", createServedArtifact(1, "a", 100, 1))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report, err := newServedApp(t, server, WithRepository("octo-org", "octo-docs")).Run(ctx)
+	if !errors.Is(err, context.Canceled) || report == nil || report.Result() != ResultCancelled {
+		t.Fatalf("expected a cancelled report, got %+v (%v)", report, err)
+	}
+	if deleted := server.Deleted("octo-org", "octo-docs"); len(deleted) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v", deleted)
+	}
+}
+
+func TestRateGate_WaitHonorsContext(t *testing.T) {
+	gate := &rateGate{}
+	gate.pause(time.Now().Add(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := gate.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to end with the context, got %v", err)
+	}
+}
+
+func TestRateGate_PauseKeepsLongest(t *testing.T) {
+	gate := &rateGate{}
+	later := time.Now().Add(time.Hour)
+	gate.pause(later)
+	gate.pause(time.Now().Add(time.Minute))
+	if !gate.until.Equal(later) {
+		t.Errorf("expected a shorter pause not to shorten the gate")
+	}
+}
+
+func TestRateLimitedUntil(t *testing.T) {
+	reset := time.Now().Add(time.Minute)
+	retryAfter := 30 * time.Second
+
+	tests := []struct {
+		name        string
+		resp        *github.Response
+		err         error
+		wantLimited bool
+	}{
+		{
+			name:        "no response",
+			wantLimited: false,
+		},
+		{
+			name:        "no rate limit headers",
+			resp:        &github.Response{},
+			wantLimited: false,
+		},
+		{
+			name:        "remaining requests",
+			resp:        &github.Response{Rate: github.Rate{Limit: 10, Remaining: 5, Reset: github.Timestamp{Time: reset}}},
+			wantLimited: false,
+		},
+		{
+			name:        "exhausted rate limit",
+			resp:        &github.Response{Rate: github.Rate{Limit: 10, Remaining: 0, Reset: github.Timestamp{Time: reset}}},
+			wantLimited: true,
+		},
+		{
+			name:        "rate limit error",
+			err:         &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: reset}}},
+			wantLimited: true,
+		},
+		{
+			name:        "secondary rate limit error",
+			err:         &github.AbuseRateLimitError{RetryAfter: &retryAfter},
+			wantLimited: true,
+		},
+		{
+			name:        "other error",
+			err:         errors.New("boom"),
+			wantLimited: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, limited := rateLimitedUntil(tt.resp, tt.err)
+			if limited != tt.wantLimited {
+				t.Errorf("expected limited=%v, got %v", tt.wantLimited, limited)
+			}
+		})
+	}
+}
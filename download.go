@@ -0,0 +1,200 @@
+package app
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultDownloadConcurrency is the number of artifacts downloaded concurrently when Concurrency isn't set
+	defaultDownloadConcurrency = 4
+	// reasonAlreadyDownloaded is reported for artifacts whose zip is already present with the size of the artifact
+	reasonAlreadyDownloaded = "already downloaded"
+	// reasonExtracted is reported for artifacts whose zip was already downloaded, and only needed extracting
+	reasonExtracted = "already downloaded, extracted"
+)
+
+// Download saves the zip of every artifact the filters select into dir, Concurrency at a time, as
+// {id}-{name}.zip. Artifacts are selected exactly as Run selects those to delete, but nothing is deleted. A zip which
+// is already present with the size of the artifact isn't downloaded again. When Extract is set, each zip is also
+// extracted into a directory of the same name. Cancelling ctx returns the partial report along with the context's
+// error, as Run does.
+func (a *App) Download(ctx context.Context, dir string) (*Report, error) {
+	if len(a.Org) > 0 {
+		return nil, errors.New("download supports a single repository, not an org")
+	}
+	if err := a.checkPreconditions(); err != nil {
+		return nil, err
+	}
+	if a.downloader == nil {
+		return nil, errors.New("downloading requires an ArtifactDownloader, see WithArtifactDownloader")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	report := newReport(*a.Owner, *a.Repo)
+	a.log().WithFields(log.Fields{"owner": *a.Owner, "repo": *a.Repo, "dir": dir}).Info("delete-artifacts is downloading from the repo")
+
+	executionContext, cancel := within(ctx, a.Timeouts.Run)
+	selected, err := a.selectArtifacts(executionContext, report)
+	cancel()
+	if err != nil {
+		report.Err = err
+		return report, err
+	}
+	report.addMatched(len(selected))
+
+	concurrency := a.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultDownloadConcurrency
+	}
+	gate := &rateGate{}
+	started := make([]atomic.Bool, len(selected))
+	parallel(ctx, concurrency, len(selected), func(i int) {
+		started[i].Store(true)
+		a.downloadArtifact(ctx, gate, dir, selected[i], report)
+	})
+	for i, artifact := range selected {
+		if !started[i].Load() {
+			report.skipped(artifact, reasonCancelled)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		report.Err = err
+		return report, err
+	}
+	return report, nil
+}
+
+// downloadArtifact saves the zip of an artifact into dir, extracting it when Extract is set, and records the outcome
+func (a *App) downloadArtifact(ctx context.Context, gate *rateGate, dir string, artifact *github.Artifact, report *Report) {
+	fields := log.Fields{"id": artifact.GetID(), "name": artifact.GetName()}
+	if ctx.Err() != nil {
+		report.skipped(artifact, reasonCancelled)
+		return
+	}
+	if artifact.GetExpired() {
+		a.log().WithFields(fields).Warn("Skipping expired artifact, which can no longer be downloaded.")
+		report.skipped(artifact, "expired")
+		return
+	}
+
+	ctx, cancel := within(ctx, a.Timeouts.Archive)
+	defer cancel()
+
+	name := fileName(artifact)
+	zipPath := filepath.Join(dir, name+".zip")
+	present, extracted := false, false
+	if info, err := os.Stat(zipPath); err == nil && info.Mode().IsRegular() && info.Size() == artifact.GetSizeInBytes() {
+		present = true
+	} else if _, err := a.storeZip(ctx, gate, &DirStore{Dir: dir}, artifact, name+".zip"); err != nil {
+		switch {
+		case errors.Is(err, errArtifactGone):
+			report.skipped(artifact, "no longer exists")
+		case ctx.Err() != nil:
+			report.skipped(artifact, reasonCancelled)
+		default:
+			a.log().WithError(err).Warnf("Error downloading %s (artifact ID %d), ignoring…", artifact.GetName(), artifact.GetID())
+			report.failed(artifact, fmt.Errorf("download failed: %w", err))
+		}
+		return
+	}
+
+	if a.Extract {
+		target := filepath.Join(dir, name)
+		if _, err := os.Stat(target); errors.Is(err, os.ErrNotExist) {
+			extracted = true
+			if err := extractZip(zipPath, target); err != nil {
+				a.log().WithError(err).Warnf("Error extracting %s (artifact ID %d), ignoring…", artifact.GetName(), artifact.GetID())
+				report.failed(artifact, fmt.Errorf("extract failed: %w", err))
+				return
+			}
+		}
+	}
+
+	switch {
+	case present && extracted:
+		a.log().WithFields(fields).Info("Extracted artifact, which was already downloaded")
+		report.skipped(artifact, reasonExtracted)
+		return
+	case present:
+		a.log().WithFields(fields).Debug("Artifact was already downloaded.")
+		report.skipped(artifact, reasonAlreadyDownloaded)
+		return
+	}
+	a.log().WithFields(fields).Info("Downloaded artifact")
+	report.downloaded(artifact)
+}
+
+// extractZip extracts the zip at zipPath into the directory target, which only appears once every file is extracted.
+// Entries which would be extracted outside of target are rejected.
+func extractZip(zipPath string, target string) (err error) {
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = archive.Close() }()
+
+	partial, err := os.MkdirTemp(filepath.Dir(target), ".partial-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(partial)
+		}
+	}()
+
+	for _, f := range archive.File {
+		name := filepath.FromSlash(f.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("zip entry %q is outside of the artifact", f.Name)
+		}
+		path := filepath.Join(partial, name)
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			return fmt.Errorf("zip entry %q is not a regular file", f.Name)
+		}
+		if err := extractFile(f, path); err != nil {
+			return err
+		}
+	}
+	return os.Rename(partial, target)
+}
+
+func extractFile(f *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	w, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,198 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+// zipOf builds a zip holding a file of each name, with its name as content
+func zipOf(t *testing.T, names ...string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, _ = f.Write([]byte(name))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDownload(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	expired := createServedArtifact(4, "logs", 10, 1)
+	expired.Expired = github.Ptr(true)
+	server.AddArtifacts("octo-org", "octo-docs",
+		createServedArtifact(1, "dist", 10, 1),
+		createServedArtifact(2, "coverage", 20, 1),
+		createServedArtifact(3, "docs", 5, 1),
+		expired,
+		createServedArtifact(5, "unavailable", 10, 1),
+	)
+	server.InjectFailure(fakegithub.Failure{Method: http.MethodGet, Path: "/_blobs/octo-org/octo-docs/5", Status: http.StatusInternalServerError})
+
+	dir := t.TempDir()
+	// a complete zip isn't downloaded again, but an incomplete one is
+	_ = os.WriteFile(filepath.Join(dir, "2-coverage.zip"), []byte("already downloaded!!"), 0o644)
+	_ = os.WriteFile(filepath.Join(dir, "1-dist.zip"), []byte("abc"), 0o644)
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+	app.MinBytes = 10
+	report, err := app.Download(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.Matched != 4 || report.Downloaded != 1 || report.Skipped != 2 || report.Failed != 1 {
+		t.Errorf("expected 1 downloaded, 2 skipped and 1 failed of 4, got %+v", report)
+	}
+	reasons := map[int64]string{}
+	for _, outcome := range report.Outcomes {
+		reasons[outcome.Artifact.GetID()] = outcome.Reason
+	}
+	if reasons[2] != reasonAlreadyDownloaded || reasons[4] != "expired" {
+		t.Errorf("unexpected reasons %v", reasons)
+	}
+
+	if zip, err := os.ReadFile(filepath.Join(dir, "1-dist.zip")); err != nil || string(zip) != "abcdefghij" {
+		t.Errorf("expected the zip of artifact 1 to be downloaded again, got %q (%v)", zip, err)
+	}
+	if zip, _ := os.ReadFile(filepath.Join(dir, "2-coverage.zip")); string(zip) != "already downloaded!!" {
+		t.Errorf("expected the zip of artifact 2 to be kept, got %q", zip)
+	}
+	entries, _ := os.ReadDir(dir)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "1-dist.zip,2-coverage.zip" {
+		t.Errorf("expected only the matched zips and no partial downloads, got %v", names)
+	}
+	if deleted := server.Deleted("octo-org", "octo-docs"); len(deleted) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v", deleted)
+	}
+}
+
+func TestDownload_RunID(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs",
+		createServedArtifact(1, "dist", 10, 1),
+		createServedArtifact(2, "dist", 10, 2),
+	)
+
+	dir := t.TempDir()
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+	app.MinBytes = 0
+	app.RunId = github.Ptr(int64(2))
+	report, err := app.Download(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Downloaded != 1 || report.Outcomes[0].Artifact.GetID() != 2 {
+		t.Errorf("expected only the artifact of run 2 to be downloaded, got %+v", report)
+	}
+}
+
+func TestDownload_Extract(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   []string
+		wantFiles []string
+		wantErr   string
+	}{
+		{"files", []string{"index.html", "css/site.css"}, []string{"css/site.css", "index.html"}, ""},
+		{"zip slip", []string{"index.html", "../escaped.txt"}, nil, "outside of the artifact"},
+		{"absolute", []string{"/etc/escaped.txt"}, nil, "outside of the artifact"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakegithub.NewServer()
+			defer server.Close()
+			content := zipOf(t, tt.entries...)
+			server.AddArtifacts("octo-org", "octo-docs", createServedArtifact(1, "site", int64(len(content)), 1))
+			server.SetArtifactContent("octo-org", "octo-docs", 1, content)
+
+			root := t.TempDir()
+			dir := filepath.Join(root, "downloads")
+			app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+			app.MinBytes = 0
+			app.Extract = true
+			report, err := app.Download(context.Background(), dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(tt.wantErr) > 0 {
+				if report.Failed != 1 || !strings.Contains(report.Outcomes[0].Err.Error(), tt.wantErr) {
+					t.Errorf("expected extracting to fail with %q, got %+v", tt.wantErr, report.Outcomes[0])
+				}
+				if _, err := os.Stat(filepath.Join(root, "escaped.txt")); err == nil {
+					t.Error("expected no file to be extracted outside of the artifact")
+				}
+				if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+					t.Errorf("expected only the zip to remain, got %v", entries)
+				}
+				return
+			}
+
+			if report.Downloaded != 1 {
+				t.Fatalf("expected the artifact to be downloaded, got %+v", report.Outcomes[0])
+			}
+			for _, name := range tt.wantFiles {
+				content, err := os.ReadFile(filepath.Join(dir, "1-site", filepath.FromSlash(name)))
+				if err != nil || string(content) != name {
+					t.Errorf("expected %s to be extracted, got %q (%v)", name, content, err)
+				}
+			}
+
+			// downloading again finds the zip and its extracted files present
+			report, _ = app.Download(context.Background(), dir)
+			if report.Skipped != 1 || report.Outcomes[0].Reason != reasonAlreadyDownloaded {
+				t.Errorf("expected the artifact to already be downloaded, got %+v", report.Outcomes[0])
+			}
+
+			// only extracting an artifact already downloaded doesn't count as a download
+			if err := os.RemoveAll(filepath.Join(dir, "1-site")); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			report, _ = app.Download(context.Background(), dir)
+			if report.Downloaded != 0 || report.Skipped != 1 || report.Outcomes[0].Reason != reasonExtracted {
+				t.Errorf("expected the artifact to only be extracted, got %+v", report.Outcomes[0])
+			}
+			if _, err := os.Stat(filepath.Join(dir, "1-site", filepath.FromSlash(tt.wantFiles[0]))); err != nil {
+				t.Errorf("expected the artifact to be extracted again: %v", err)
+			}
+		})
+	}
+}
+
+func TestDownload_RejectsOrg(t *testing.T) {
+	app, _ := NewWithOptions(WithArtifactService(&fakeArtifactService{}), WithLogger(quietLogger()))
+	app.Org = "octo-org"
+	if _, err := app.Download(context.Background(), t.TempDir()); err == nil || !strings.Contains(err.Error(), "not an org") {
+		t.Errorf("expected downloading from an org to fail, got %v", err)
+	}
+}
+
+func TestDownload_RequiresDownloader(t *testing.T) {
+	app, _ := NewWithOptions(WithArtifactService(&fakeArtifactService{}), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+	if _, err := app.Download(context.Background(), t.TempDir()); err == nil {
+		t.Error("expected downloading without an ArtifactDownloader to fail")
+	}
+}
@@ -0,0 +1,142 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// Endpoint configures the GitHub server to use, such as a GitHub Enterprise Server, and how it is reached
+type Endpoint struct {
+	// APIURL is the base URL of the REST API, such as https://github.example.com/api/v3. Empty targets api.github.com.
+	APIURL string
+	// UploadURL is the base URL for uploads, which is derived from APIURL when empty
+	UploadURL string
+	// CAFile is the path to a PEM bundle of certificate authorities trusted in addition to those of the system
+	CAFile string
+	// ClientCertFile and ClientKeyFile are the paths to a PEM certificate and key presented to the server
+	ClientCertFile string
+	ClientKeyFile  string
+	// ProxyURL is the URL of the proxy for every request. When empty, HTTPS_PROXY, HTTP_PROXY and NO_PROXY are honored.
+	ProxyURL string
+}
+
+// WithEndpoint targets a GitHub Enterprise Server, or reaches GitHub via a custom CA, client certificate or proxy.
+// It applies to the client created by NewWithOptions, and is ignored along with WithClient or WithArtifactService.
+func WithEndpoint(endpoint Endpoint) Option {
+	return func(a *App) {
+		a.endpoint = &endpoint
+	}
+}
+
+// validate checks the URLs and certificate settings, so that misconfiguration is reported before any request is made
+func (e *Endpoint) validate() error {
+	if len(e.UploadURL) > 0 && len(e.APIURL) == 0 {
+		return errors.New("upload url requires an api url")
+	}
+	if err := checkURL("api url", e.APIURL, "http", "https"); err != nil {
+		return err
+	}
+	if err := checkURL("upload url", e.UploadURL, "http", "https"); err != nil {
+		return err
+	}
+	if err := checkURL("proxy url", e.ProxyURL, "http", "https", "socks5"); err != nil {
+		return err
+	}
+	if (len(e.ClientCertFile) == 0) != (len(e.ClientKeyFile) == 0) {
+		return errors.New("client certificate and client key must be set together")
+	}
+	return nil
+}
+
+func checkURL(name, value string, schemes ...string) error {
+	if len(value) == 0 {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%s %q is invalid: %w", name, value, err)
+	}
+	if len(u.Host) == 0 {
+		return fmt.Errorf("%s %q is invalid: expected an absolute URL such as https://github.example.com/api/v3", name, value)
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %q is invalid: scheme must be one of %s", name, value, strings.Join(schemes, ", "))
+}
+
+// transport returns the HTTP transport beneath authentication, trusting CAFile and presenting the client certificate
+func (e *Endpoint) transport() (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if len(e.CAFile) > 0 {
+		b, err := os.ReadFile(e.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("CA file %s holds no PEM certificates", e.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(e.ClientCertFile) > 0 {
+		certificate, err := tls.LoadX509KeyPair(e.ClientCertFile, e.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if len(e.ProxyURL) > 0 {
+		// validated by validate
+		proxy, _ := url.Parse(e.ProxyURL)
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return transport, nil
+}
+
+// enterprise is true when the endpoint targets a server other than api.github.com
+func (e *Endpoint) enterprise() bool {
+	if len(e.APIURL) == 0 {
+		return false
+	}
+	u, err := url.Parse(e.APIURL)
+	return err != nil || u.Host != "api.github.com"
+}
+
+// uploadURL returns UploadURL, or derives it from the /api/v3 path of APIURL
+func (e *Endpoint) uploadURL() string {
+	if len(e.UploadURL) > 0 {
+		return e.UploadURL
+	}
+	u, _ := url.Parse(e.APIURL)
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/api/v3") + "/api/uploads/"
+	return u.String()
+}
+
+// newGitHubClient creates a client sending requests via httpClient to the configured endpoint
+func (a *App) newGitHubClient(httpClient *http.Client) *github.Client {
+	client := github.NewClient(httpClient)
+	if a.endpoint != nil && a.endpoint.enterprise() {
+		// the URLs were validated by NewWithOptions
+		client, _ = client.WithEnterpriseURLs(a.endpoint.APIURL, a.endpoint.uploadURL())
+	}
+	return client
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+// writeCertificate writes a self-signed certificate and its key as PEM files, returning their paths
+func writeCertificate(t *testing.T) (string, string) {
+	t.Helper()
+	key := generateKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "delete-artifacts"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	_ = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	_ = os.WriteFile(keyFile, encodePKCS1(key), 0600)
+	return certFile, keyFile
+}
+
+func TestEndpoint_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint Endpoint
+		wantErr  string
+	}{
+		{"empty", Endpoint{}, ""},
+		{"enterprise", Endpoint{APIURL: "https://github.example.com/api/v3", UploadURL: "https://github.example.com/api/uploads"}, ""},
+		{"relative api url", Endpoint{APIURL: "github.example.com/api/v3"}, "expected an absolute URL"},
+		{"unsupported scheme", Endpoint{APIURL: "ftp://github.example.com"}, "scheme must be one of http, https"},
+		{"upload without api", Endpoint{UploadURL: "https://github.example.com/api/uploads"}, "upload url requires an api url"},
+		{"socks proxy", Endpoint{ProxyURL: "socks5://proxy.example.com:1080"}, ""},
+		{"invalid proxy", Endpoint{ProxyURL: "://proxy"}, "proxy url"},
+		{"certificate without key", Endpoint{ClientCertFile: "client.pem"}, "must be set together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.endpoint.validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEndpoint_URLs(t *testing.T) {
+	tests := []struct {
+		name       string
+		endpoint   Endpoint
+		enterprise bool
+		baseURL    string
+		uploadURL  string
+	}{
+		{"github.com", Endpoint{}, false, "https://api.github.com/", "https://uploads.github.com/"},
+		{"actions runner on github.com", Endpoint{APIURL: "https://api.github.com"}, false, "https://api.github.com/", "https://uploads.github.com/"},
+		{"enterprise api path", Endpoint{APIURL: "https://github.example.com/api/v3"}, true, "https://github.example.com/api/v3/", "https://github.example.com/api/uploads/"},
+		{"enterprise host", Endpoint{APIURL: "https://github.example.com"}, true, "https://github.example.com/api/v3/", "https://github.example.com/api/uploads/"},
+		{"explicit upload", Endpoint{APIURL: "https://github.example.com/api/v3", UploadURL: "https://uploads.example.com"}, true, "https://github.example.com/api/v3/", "https://uploads.example.com/api/uploads/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.endpoint.enterprise(); got != tt.enterprise {
+				t.Errorf("enterprise() = %v, want %v", got, tt.enterprise)
+			}
+			client := (&App{endpoint: &tt.endpoint}).newGitHubClient(http.DefaultClient)
+			if client.BaseURL.String() != tt.baseURL || client.UploadURL.String() != tt.uploadURL {
+				t.Errorf("expected %s and %s, got %s and %s", tt.baseURL, tt.uploadURL, client.BaseURL, client.UploadURL)
+			}
+		})
+	}
+}
+
+func TestEndpoint_Transport(t *testing.T) {
+	certFile, keyFile := writeCertificate(t)
+	endpoint := Endpoint{ClientCertFile: certFile, ClientKeyFile: keyFile, ProxyURL: "http://proxy.example.com:3128"}
+	transport, err := endpoint.transport()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(transport.TLSClientConfig.Certificates) != 1 {
+		t.Errorf("expected the client certificate to be presented, got %d certificates", len(transport.TLSClientConfig.Certificates))
+	}
+	request, _ := http.NewRequest(http.MethodGet, "https://github.example.com/api/v3/", nil)
+	if proxy, _ := transport.Proxy(request); proxy == nil || proxy.Host != "proxy.example.com:3128" {
+		t.Errorf("expected requests via proxy.example.com:3128, got %v", proxy)
+	}
+
+	// a key file isn't a CA bundle
+	if _, err := (&Endpoint{CAFile: keyFile}).transport(); err == nil || !strings.Contains(err.Error(), "no PEM certificates") {
+		t.Errorf("expected an error for a CA file without certificates, got %v", err)
+	}
+}
+
+func TestEndpoint_EnterpriseServer(t *testing.T) {
+	server := fakegithub.NewEnterpriseServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", createServedArtifact(1, "a", 1000, 1))
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	_ = os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	t.Setenv("GITHUB_TOKEN", "ghes-token")
+
+	tests := []struct {
+		name    string
+		caFile  string
+		wantErr bool
+	}{
+		{"untrusted", "", true},
+		{"trusted", caFile, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, err := NewWithOptions(
+				WithEndpoint(Endpoint{APIURL: server.URL + "/api/v3", CAFile: tt.caFile}),
+				WithRepository("octo-org", "octo-docs"),
+				WithLogger(quietLogger()),
+				WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			report, err := app.Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && report.Deleted != 1 {
+				t.Errorf("expected the artifact to be deleted, got %+v", report)
+			}
+		})
+	}
+
+	for _, authorization := range server.Authorizations() {
+		if authorization != "Bearer ghes-token" {
+			t.Errorf("expected requests authenticated with GITHUB_TOKEN, got %q", authorization)
+		}
+	}
+}
+
+func TestNewWithOptions_InvalidEndpoint(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "token")
+	if _, err := NewWithOptions(WithEndpoint(Endpoint{APIURL: "github.example.com"})); err == nil {
+		t.Error("expected an invalid api url to fail at construction")
+	}
+}
@@ -0,0 +1,699 @@
+// Package fakegithub provides an in-process fake of the GitHub Actions artifacts API for tests.
+//
+// The fake serves the artifact list, workflow run artifacts, workflow run, pull request, tag, download and delete endpoints, including
+// pagination via Link headers and rate-limit headers, and supports injecting failures for specific requests. Artifact downloads
+// redirect to /_blobs/{owner}/{repo}/{id}, which serves the zip as blob storage would.
+package fakegithub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+const (
+	defaultPerPage   = 30
+	maxPerPage       = 100
+	defaultRateLimit = 5000
+)
+
+// Failure describes a response to return instead of the fake's normal behavior
+type Failure struct {
+	// Method of requests to fail, or any method when empty
+	Method string
+	// Path of requests to fail, such as /repos/octo-org/octo-docs/actions/artifacts/11
+	Path string
+	// Status code of the failed response
+	Status int
+	// Message returned in the error body
+	Message string
+	// DocumentationURL returned in the error body, which go-github uses to detect secondary rate limits
+	DocumentationURL string
+	// Header holds additional response headers, such as Retry-After
+	Header http.Header
+	// Times is the number of requests to fail, or every matching request when zero
+	Times int
+}
+
+// SecondaryRateLimit is a Failure responding as GitHub does when a secondary rate limit is exceeded
+func SecondaryRateLimit(method, path string, retryAfter time.Duration, times int) Failure {
+	return Failure{
+		Method:           method,
+		Path:             path,
+		Status:           http.StatusForbidden,
+		Message:          "You have exceeded a secondary rate limit.",
+		DocumentationURL: "https://docs.github.com/rest/overview/rate-limits-for-the-rest-api#about-secondary-rate-limits",
+		Header:           http.Header{"Retry-After": []string{strconv.Itoa(int(retryAfter.Seconds()))}},
+		Times:            times,
+	}
+}
+
+// Server is a fake GitHub API backed by in-memory state
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	artifacts    map[string][]*github.Artifact
+	deleted      map[string][]int64
+	repositories map[string][]*github.Repository
+	runs         map[string][]*github.WorkflowRun
+	pulls        map[string][]*github.PullRequest
+	tags         map[string][]*github.RepositoryTag
+	contents     map[string][]byte
+	failures     []*Failure
+	requests     []string
+
+	installations  map[string]int64
+	tokens         []string
+	authorizations []string
+	tokenLifetime  time.Duration
+
+	// prefix is the path beneath which the API is served
+	prefix string
+
+	rateLimit     int
+	rateRemaining int
+	rateReset     time.Time
+}
+
+// NewServer starts a fake GitHub API server. Callers must Close it when done.
+func NewServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewServer(s.middleware(s.routes()))
+	return s
+}
+
+// NewEnterpriseServer starts a fake GitHub Enterprise Server, which serves the API over TLS beneath /api/v3.
+// Clients must trust its Certificate, as the client of GitHubClient does. Callers must Close it when done.
+func NewEnterpriseServer() *Server {
+	s := newServer()
+	s.prefix = "/api/v3"
+	s.Server = httptest.NewTLSServer(http.StripPrefix(s.prefix, s.middleware(s.routes())))
+	return s
+}
+
+func newServer() *Server {
+	return &Server{
+		artifacts:     make(map[string][]*github.Artifact),
+		deleted:       make(map[string][]int64),
+		repositories:  make(map[string][]*github.Repository),
+		runs:          make(map[string][]*github.WorkflowRun),
+		pulls:         make(map[string][]*github.PullRequest),
+		tags:          make(map[string][]*github.RepositoryTag),
+		contents:      make(map[string][]byte),
+		installations: make(map[string]int64),
+		tokenLifetime: time.Hour,
+		rateLimit:     defaultRateLimit,
+		rateRemaining: defaultRateLimit,
+		rateReset:     time.Now().Add(time.Hour).Truncate(time.Second),
+	}
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/artifacts", s.listArtifacts)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/runs/{run}/artifacts", s.listRunArtifacts)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/runs/{run}", s.getWorkflowRun)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/artifacts/{id}", s.getArtifact)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/artifacts/{id}/zip", s.downloadArtifact)
+	mux.HandleFunc("GET /_blobs/{owner}/{repo}/{id}", s.getBlob)
+	mux.HandleFunc("DELETE /repos/{owner}/{repo}/actions/artifacts/{id}", s.deleteArtifact)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls", s.listPullRequests)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls/{number}", s.getPullRequest)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/tags", s.listTags)
+	mux.HandleFunc("GET /orgs/{org}/repos", s.listOrgRepositories)
+	mux.HandleFunc("GET /users/{user}/repos", s.listUserRepositories)
+	mux.HandleFunc("GET /orgs/{org}/installation", s.findOrgInstallation)
+	mux.HandleFunc("GET /users/{user}/installation", s.findUserInstallation)
+	mux.HandleFunc("POST /app/installations/{id}/access_tokens", s.createInstallationToken)
+	return mux
+}
+
+// GitHubClient returns a client targeting the fake server
+func (s *Server) GitHubClient() *github.Client {
+	return s.GitHubClientWith(s.Client())
+}
+
+// GitHubClientWith returns a client targeting the fake server, which sends requests via httpClient
+func (s *Server) GitHubClientWith(httpClient *http.Client) *github.Client {
+	client := github.NewClient(httpClient)
+	u, _ := url.Parse(s.URL + s.prefix + "/")
+	client.BaseURL = u
+	client.UploadURL = u
+	return client
+}
+
+// AddArtifacts adds artifacts to a repository. Artifacts are associated with a workflow run via their WorkflowRun.ID.
+func (s *Server) AddArtifacts(owner, repo string, artifacts ...*github.Artifact) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := owner + "/" + repo
+	s.artifacts[key] = append(s.artifacts[key], artifacts...)
+}
+
+// AddWorkflowRuns adds workflow runs to a repository, which produce the artifacts with a matching WorkflowRun.ID
+func (s *Server) AddWorkflowRuns(owner, repo string, runs ...*github.WorkflowRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := owner + "/" + repo
+	s.runs[key] = append(s.runs[key], runs...)
+}
+
+// AddPullRequests adds pull requests to a repository. Their Head.Label, such as octocat:feature, is matched by the
+// head filter of the list endpoint.
+func (s *Server) AddPullRequests(owner, repo string, pulls ...*github.PullRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := owner + "/" + repo
+	s.pulls[key] = append(s.pulls[key], pulls...)
+}
+
+// SetArtifactContent sets the zip served when downloading an artifact. Artifacts without content serve SizeInBytes
+// bytes of filler.
+func (s *Server) SetArtifactContent(owner, repo string, id int64, content []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contents[fmt.Sprintf("%s/%s/%d", owner, repo, id)] = content
+}
+
+// AddTags adds tags to a repository
+func (s *Server) AddTags(owner, repo string, tags ...*github.RepositoryTag) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := owner + "/" + repo
+	s.tags[key] = append(s.tags[key], tags...)
+}
+
+// LoadFixture adds the artifacts of a JSON artifact list, such as the response of the list artifacts API, to a repository
+func (s *Server) LoadFixture(owner, repo, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	list := &github.ArtifactList{}
+	if err := json.Unmarshal(b, list); err != nil {
+		return fmt.Errorf("unable to parse fixture %s: %w", path, err)
+	}
+	s.AddArtifacts(owner, repo, list.Artifacts...)
+	return nil
+}
+
+// AddRepositories adds repositories to an org or user
+func (s *Server) AddRepositories(owner string, repositories ...*github.Repository) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repositories[owner] = append(s.repositories[owner], repositories...)
+}
+
+// AddInstallation installs a GitHub App for an org or user. Installation tokens are issued to requests authenticated
+// with any bearer token, and are not checked by other endpoints.
+func (s *Server) AddInstallation(owner string, id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.installations[owner] = id
+}
+
+// SetTokenLifetime sets how long issued installation tokens are valid, which defaults to an hour
+func (s *Server) SetTokenLifetime(lifetime time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenLifetime = lifetime
+}
+
+// Tokens returns the installation tokens issued, in order
+func (s *Server) Tokens() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.tokens...)
+}
+
+// Authorizations returns the Authorization header of every request received
+func (s *Server) Authorizations() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.authorizations...)
+}
+
+// Artifacts returns the artifacts remaining in a repository
+func (s *Server) Artifacts(owner, repo string) []*github.Artifact {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*github.Artifact(nil), s.artifacts[owner+"/"+repo]...)
+}
+
+// Deleted returns the ids of artifacts deleted from a repository, in the order of deletion
+func (s *Server) Deleted(owner, repo string) []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.deleted[owner+"/"+repo]...)
+}
+
+// Requests returns every request received, formatted as "METHOD /path?query" relative to the root of the API
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// InjectFailure fails matching requests until the failure has been returned Times times
+func (s *Server) InjectFailure(failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := failure
+	s.failures = append(s.failures, &f)
+}
+
+// SetRateLimit sets the primary rate limit reported in response headers. Once remaining reaches zero,
+// requests fail with a rate limit error until reset.
+func (s *Server) SetRateLimit(limit, remaining int, reset time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit = limit
+	s.rateRemaining = remaining
+	// the reset header has a resolution of seconds
+	s.rateReset = reset.Truncate(time.Second)
+}
+
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+		s.authorizations = append(s.authorizations, r.Header.Get("Authorization"))
+
+		if !time.Now().Before(s.rateReset) {
+			s.rateRemaining = s.rateLimit
+			s.rateReset = time.Now().Add(time.Hour).Truncate(time.Second)
+		}
+		limited := s.rateRemaining <= 0
+		if !limited {
+			s.rateRemaining--
+		}
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.rateLimit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.rateRemaining))
+		w.Header().Set("X-RateLimit-Used", strconv.Itoa(s.rateLimit-s.rateRemaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(s.rateReset.Unix(), 10))
+		w.Header().Set("X-RateLimit-Resource", "core")
+
+		failure := s.takeFailure(r)
+		s.mu.Unlock()
+
+		if limited {
+			writeError(w, http.StatusForbidden, "API rate limit exceeded")
+			return
+		}
+
+		if failure != nil {
+			for k, values := range failure.Header {
+				for _, v := range values {
+					w.Header().Add(k, v)
+				}
+			}
+			message := failure.Message
+			if len(message) == 0 {
+				message = http.StatusText(failure.Status)
+			}
+			writeErrorWithDocumentation(w, failure.Status, message, failure.DocumentationURL)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// takeFailure returns the first failure matching the request; callers must hold the lock
+func (s *Server) takeFailure(r *http.Request) *Failure {
+	for i, f := range s.failures {
+		if (len(f.Method) > 0 && f.Method != r.Method) || f.Path != r.URL.Path {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func (s *Server) listArtifacts(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	all := s.artifacts[r.PathValue("owner")+"/"+r.PathValue("repo")]
+	name := r.URL.Query().Get("name")
+	matched := make([]*github.Artifact, 0, len(all))
+	for _, artifact := range all {
+		if len(name) == 0 || artifact.GetName() == name {
+			matched = append(matched, artifact)
+		}
+	}
+	s.mu.Unlock()
+
+	writeArtifactPage(w, r, matched)
+}
+
+func (s *Server) listRunArtifacts(w http.ResponseWriter, r *http.Request) {
+	runID, err := strconv.ParseInt(r.PathValue("run"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	all := s.artifacts[r.PathValue("owner")+"/"+r.PathValue("repo")]
+	matched := make([]*github.Artifact, 0, len(all))
+	for _, artifact := range all {
+		if artifact.GetWorkflowRun().GetID() == runID {
+			matched = append(matched, artifact)
+		}
+	}
+	s.mu.Unlock()
+
+	writeArtifactPage(w, r, matched)
+}
+
+func (s *Server) getArtifact(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, artifact := range s.artifacts[r.PathValue("owner")+"/"+r.PathValue("repo")] {
+		if artifact.GetID() == id {
+			writeJSON(w, http.StatusOK, artifact)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+// findArtifact returns an artifact of the repository of the request. Callers must hold mu.
+func (s *Server) findArtifact(r *http.Request) *github.Artifact {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return nil
+	}
+	for _, artifact := range s.artifacts[r.PathValue("owner")+"/"+r.PathValue("repo")] {
+		if artifact.GetID() == id {
+			return artifact
+		}
+	}
+	return nil
+}
+
+func (s *Server) downloadArtifact(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	artifact := s.findArtifact(r)
+	s.mu.Unlock()
+
+	switch {
+	case artifact == nil:
+		writeError(w, http.StatusNotFound, "Not Found")
+	case artifact.GetExpired():
+		writeError(w, http.StatusGone, "Artifact has expired")
+	default:
+		w.Header().Set("Location", fmt.Sprintf("%s%s/_blobs/%s/%s/%d", s.URL, s.prefix, r.PathValue("owner"), r.PathValue("repo"), artifact.GetID()))
+		w.WriteHeader(http.StatusFound)
+	}
+}
+
+func (s *Server) getBlob(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	artifact := s.findArtifact(r)
+	var content []byte
+	if artifact != nil {
+		var ok bool
+		content, ok = s.contents[fmt.Sprintf("%s/%s/%d", r.PathValue("owner"), r.PathValue("repo"), artifact.GetID())]
+		if !ok {
+			content = filler(artifact.GetSizeInBytes())
+		}
+	}
+	s.mu.Unlock()
+
+	if artifact == nil {
+		http.Error(w, "BlobNotFound", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	_, _ = w.Write(content)
+}
+
+// filler is the content of an artifact of size bytes without content
+func filler(size int64) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte('a' + i%26)
+	}
+	return content
+}
+
+func (s *Server) getWorkflowRun(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("run"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.runs[r.PathValue("owner")+"/"+r.PathValue("repo")] {
+		if run.GetID() == id {
+			writeJSON(w, http.StatusOK, run)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) listPullRequests(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	state := query.Get("state")
+	if len(state) == 0 {
+		state = "open"
+	}
+
+	s.mu.Lock()
+	matched := make([]*github.PullRequest, 0)
+	for _, pull := range s.pulls[r.PathValue("owner")+"/"+r.PathValue("repo")] {
+		if state != "all" && pull.GetState() != state {
+			continue
+		}
+		if head := query.Get("head"); len(head) > 0 && pull.GetHead().GetLabel() != head {
+			continue
+		}
+		matched = append(matched, pull)
+	}
+	s.mu.Unlock()
+
+	start, end := paginate(w, r, len(matched))
+	writeJSON(w, http.StatusOK, matched[start:end])
+}
+
+func (s *Server) getPullRequest(w http.ResponseWriter, r *http.Request) {
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, pull := range s.pulls[r.PathValue("owner")+"/"+r.PathValue("repo")] {
+		if pull.GetNumber() == number {
+			writeJSON(w, http.StatusOK, pull)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	tags := append([]*github.RepositoryTag(nil), s.tags[r.PathValue("owner")+"/"+r.PathValue("repo")]...)
+	s.mu.Unlock()
+
+	start, end := paginate(w, r, len(tags))
+	writeJSON(w, http.StatusOK, tags[start:end])
+}
+
+func (s *Server) deleteArtifact(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := r.PathValue("owner") + "/" + r.PathValue("repo")
+	for i, artifact := range s.artifacts[key] {
+		if artifact.GetID() == id {
+			s.artifacts[key] = append(s.artifacts[key][:i:i], s.artifacts[key][i+1:]...)
+			s.deleted[key] = append(s.deleted[key], id)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) listOrgRepositories(w http.ResponseWriter, r *http.Request) {
+	s.listRepositories(w, r, r.PathValue("org"))
+}
+
+func (s *Server) listUserRepositories(w http.ResponseWriter, r *http.Request) {
+	s.listRepositories(w, r, r.PathValue("user"))
+}
+
+func (s *Server) listRepositories(w http.ResponseWriter, r *http.Request, owner string) {
+	s.mu.Lock()
+	repositories, found := s.repositories[owner]
+	repositories = append([]*github.Repository(nil), repositories...)
+	s.mu.Unlock()
+
+	if !found {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	start, end := paginate(w, r, len(repositories))
+	writeJSON(w, http.StatusOK, repositories[start:end])
+}
+
+func (s *Server) findOrgInstallation(w http.ResponseWriter, r *http.Request) {
+	s.findInstallation(w, r.PathValue("org"))
+}
+
+func (s *Server) findUserInstallation(w http.ResponseWriter, r *http.Request) {
+	s.findInstallation(w, r.PathValue("user"))
+}
+
+func (s *Server) findInstallation(w http.ResponseWriter, owner string) {
+	s.mu.Lock()
+	id, found := s.installations[owner]
+	s.mu.Unlock()
+
+	if !found {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, &github.Installation{ID: &id, Account: &github.User{Login: &owner}})
+}
+
+func (s *Server) createInstallationToken(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		writeError(w, http.StatusUnauthorized, "A JSON web token could not be decoded")
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	installed := false
+	for _, installation := range s.installations {
+		installed = installed || installation == id
+	}
+	if !installed {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	token := fmt.Sprintf("ghs_fake_%d_%d", id, len(s.tokens)+1)
+	s.tokens = append(s.tokens, token)
+	expiresAt := &github.Timestamp{Time: time.Now().Add(s.tokenLifetime)}
+	writeJSON(w, http.StatusCreated, &github.InstallationToken{Token: &token, ExpiresAt: expiresAt})
+}
+
+func writeArtifactPage(w http.ResponseWriter, r *http.Request, artifacts []*github.Artifact) {
+	start, end := paginate(w, r, len(artifacts))
+	total := int64(len(artifacts))
+	writeJSON(w, http.StatusOK, &github.ArtifactList{TotalCount: &total, Artifacts: artifacts[start:end]})
+}
+
+// paginate writes the Link header for the requested page and returns the bounds of that page
+func paginate(w http.ResponseWriter, r *http.Request, total int) (int, int) {
+	query := r.URL.Query()
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+
+	lastPage := (total + perPage - 1) / perPage
+	if lastPage == 0 {
+		lastPage = 1
+	}
+
+	links := make([]string, 0, 4)
+	link := func(p int, rel string) {
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(p))
+		q.Set("per_page", strconv.Itoa(perPage))
+		u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path, RawQuery: q.Encode()}
+		links = append(links, fmt.Sprintf("<%s>; rel=\"%s\"", u.String(), rel))
+	}
+	if page > 1 {
+		link(page-1, "prev")
+		link(1, "first")
+	}
+	if page < lastPage {
+		link(page+1, "next")
+		link(lastPage, "last")
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeErrorWithDocumentation(w, status, message, "")
+}
+
+func writeErrorWithDocumentation(w http.ResponseWriter, status int, message string, documentationURL string) {
+	if len(documentationURL) == 0 {
+		documentationURL = "https://docs.github.com/rest"
+	}
+	writeJSON(w, status, map[string]string{
+		"message":           message,
+		"documentation_url": documentationURL,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,335 @@
+package fakegithub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+func artifact(id int64, name string, runID int64) *github.Artifact {
+	return &github.Artifact{
+		ID:          &id,
+		Name:        &name,
+		SizeInBytes: github.Ptr(int64(100)),
+		WorkflowRun: &github.ArtifactWorkflowRun{ID: &runID},
+	}
+}
+
+func TestServer_LoadFixture(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	if err := server.LoadFixture("octo-org", "octo-docs", "../testdata/artifact_example.json"); err != nil {
+		t.Fatalf("unexpected error loading fixture: %v", err)
+	}
+
+	list, _, err := server.GitHubClient().Actions.ListArtifacts(context.Background(), "octo-org", "octo-docs", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if list.GetTotalCount() != 2 || len(list.Artifacts) != 2 {
+		t.Fatalf("expected 2 artifacts, got %d", len(list.Artifacts))
+	}
+	if list.Artifacts[0].GetID() != 11 || list.Artifacts[0].GetName() != "Rails" {
+		t.Errorf("unexpected first artifact: %v", list.Artifacts[0])
+	}
+}
+
+func TestServer_Pagination(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	for i := int64(1); i <= 5; i++ {
+		server.AddArtifacts("octo-org", "octo-docs", artifact(i, "a", 1))
+	}
+
+	client := server.GitHubClient()
+	opts := &github.ListArtifactsOptions{ListOptions: github.ListOptions{PerPage: 2}}
+	seen := make([]int64, 0)
+	pages := 0
+	for {
+		list, resp, err := client.Actions.ListArtifacts(context.Background(), "octo-org", "octo-docs", opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		pages++
+		if list.GetTotalCount() != 5 {
+			t.Errorf("expected total_count 5, got %d", list.GetTotalCount())
+		}
+		for _, a := range list.Artifacts {
+			seen = append(seen, a.GetID())
+		}
+		if resp.LastPage != 0 && resp.LastPage != 3 {
+			t.Errorf("expected last page 3, got %d", resp.LastPage)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	if pages != 3 || len(seen) != 5 {
+		t.Errorf("expected 5 artifacts over 3 pages, got %d over %d", len(seen), pages)
+	}
+}
+
+func TestServer_RunArtifacts(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", artifact(1, "a", 100), artifact(2, "b", 200), artifact(3, "c", 100))
+
+	list, _, err := server.GitHubClient().Actions.ListWorkflowRunArtifacts(context.Background(), "octo-org", "octo-docs", 100, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list.Artifacts) != 2 || list.Artifacts[0].GetID() != 1 || list.Artifacts[1].GetID() != 3 {
+		t.Errorf("expected artifacts 1 and 3 of run 100, got %v", list.Artifacts)
+	}
+}
+
+func TestServer_GetArtifact(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", artifact(1, "a", 100))
+	client := server.GitHubClient()
+
+	found, _, err := client.Actions.GetArtifact(context.Background(), "octo-org", "octo-docs", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found.GetName() != "a" || found.GetWorkflowRun().GetID() != 100 {
+		t.Errorf("expected artifact a of run 100, got %v", found)
+	}
+
+	_, resp, err := client.Actions.GetArtifact(context.Background(), "octo-org", "octo-docs", 2)
+	if err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown artifact, got %v", err)
+	}
+}
+
+func TestServer_GetWorkflowRun(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddWorkflowRuns("octo-org", "octo-docs", &github.WorkflowRun{ID: github.Ptr(int64(100)), Event: github.Ptr("push")})
+	client := server.GitHubClient()
+
+	run, _, err := client.Actions.GetWorkflowRunByID(context.Background(), "octo-org", "octo-docs", 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if run.GetEvent() != "push" {
+		t.Errorf("expected run 100 triggered by push, got %v", run)
+	}
+
+	_, resp, err := client.Actions.GetWorkflowRunByID(context.Background(), "octo-org", "octo-docs", 101)
+	if err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown run, got %v", err)
+	}
+}
+
+func TestServer_PullRequests(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddPullRequests("octo-org", "octo-docs",
+		&github.PullRequest{Number: github.Ptr(1), State: github.Ptr("closed"), Head: &github.PullRequestBranch{Label: github.Ptr("octocat:feature")}},
+		&github.PullRequest{Number: github.Ptr(2), State: github.Ptr("open"), Head: &github.PullRequestBranch{Label: github.Ptr("octo-org:docs")}},
+	)
+	client := server.GitHubClient()
+
+	pull, _, err := client.PullRequests.Get(context.Background(), "octo-org", "octo-docs", 1)
+	if err != nil || pull.GetState() != "closed" {
+		t.Fatalf("expected closed pull request 1, got %v (%v)", pull, err)
+	}
+
+	open, _, err := client.PullRequests.List(context.Background(), "octo-org", "octo-docs", nil)
+	if err != nil || len(open) != 1 || open[0].GetNumber() != 2 {
+		t.Errorf("expected only the open pull request by default, got %v (%v)", open, err)
+	}
+	byHead, _, err := client.PullRequests.List(context.Background(), "octo-org", "octo-docs", &github.PullRequestListOptions{State: "all", Head: "octocat:feature"})
+	if err != nil || len(byHead) != 1 || byHead[0].GetNumber() != 1 {
+		t.Errorf("expected pull request 1 for its head, got %v (%v)", byHead, err)
+	}
+}
+
+func TestServer_Tags(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	for i := 1; i <= 3; i++ {
+		server.AddTags("octo-org", "octo-docs", &github.RepositoryTag{Name: github.Ptr(fmt.Sprintf("v%d", i)), Commit: &github.Commit{SHA: github.Ptr(fmt.Sprintf("sha%d", i))}})
+	}
+	client := server.GitHubClient()
+
+	tags, resp, err := client.Repositories.ListTags(context.Background(), "octo-org", "octo-docs", &github.ListOptions{PerPage: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tags) != 2 || tags[0].GetCommit().GetSHA() != "sha1" || resp.NextPage != 2 {
+		t.Errorf("expected the first page of two tags, got %v (next page %d)", tags, resp.NextPage)
+	}
+}
+
+func TestServer_DownloadArtifact(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	filled := artifact(1, "filler", 10)
+	filled.SizeInBytes = github.Ptr(int64(10))
+	server.AddArtifacts("octo-org", "octo-docs", filled, artifact(2, "zip", 10))
+	server.SetArtifactContent("octo-org", "octo-docs", 2, []byte("PK\x03\x04"))
+	client := server.GitHubClient()
+
+	tests := []struct {
+		id   int64
+		want string
+	}{
+		{1, "abcdefghij"},
+		{2, "PK\x03\x04"},
+	}
+	for _, tt := range tests {
+		u, _, err := client.Actions.DownloadArtifact(context.Background(), "octo-org", "octo-docs", tt.id, 1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := http.Get(u.String())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if string(body) != tt.want {
+			t.Errorf("expected artifact %d to download %q, got %q", tt.id, tt.want, body)
+		}
+	}
+
+	if _, resp, err := client.Actions.DownloadArtifact(context.Background(), "octo-org", "octo-docs", 3, 1); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a missing artifact to be not found, got %v", err)
+	}
+}
+
+func TestServer_DeleteArtifact(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", artifact(1, "a", 1), artifact(2, "b", 1))
+	client := server.GitHubClient()
+
+	if _, err := client.Actions.DeleteArtifact(context.Background(), "octo-org", "octo-docs", 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := client.Actions.DeleteArtifact(context.Background(), "octo-org", "octo-docs", 1)
+	if err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 deleting an artifact twice, got %v", err)
+	}
+
+	remaining := server.Artifacts("octo-org", "octo-docs")
+	if len(remaining) != 1 || remaining[0].GetID() != 2 {
+		t.Errorf("expected only artifact 2 to remain, got %v", remaining)
+	}
+	if deleted := server.Deleted("octo-org", "octo-docs"); len(deleted) != 1 || deleted[0] != 1 {
+		t.Errorf("expected artifact 1 to be deleted, got %v", deleted)
+	}
+}
+
+func TestServer_InjectFailure(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", artifact(1, "a", 1))
+	server.InjectFailure(Failure{Method: http.MethodGet, Path: "/repos/octo-org/octo-docs/actions/artifacts", Status: http.StatusBadGateway, Times: 2})
+	client := server.GitHubClient()
+
+	for i := 0; i < 2; i++ {
+		_, resp, err := client.Actions.ListArtifacts(context.Background(), "octo-org", "octo-docs", nil)
+		if err == nil || resp.StatusCode != http.StatusBadGateway {
+			t.Fatalf("expected injected 502 on attempt %d, got %v", i+1, err)
+		}
+	}
+
+	if _, _, err := client.Actions.ListArtifacts(context.Background(), "octo-org", "octo-docs", nil); err != nil {
+		t.Errorf("expected success once the failure was exhausted, got %v", err)
+	}
+}
+
+func TestServer_SecondaryRateLimit(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", artifact(1, "a", 1))
+	server.InjectFailure(SecondaryRateLimit(http.MethodDelete, "/repos/octo-org/octo-docs/actions/artifacts/1", 3*time.Second, 1))
+
+	_, err := server.GitHubClient().Actions.DeleteArtifact(context.Background(), "octo-org", "octo-docs", 1)
+	var abuse *github.AbuseRateLimitError
+	if !errors.As(err, &abuse) {
+		t.Fatalf("expected a secondary rate limit error, got %v", err)
+	}
+	if abuse.GetRetryAfter() != 3*time.Second {
+		t.Errorf("expected Retry-After of 3s, got %s", abuse.GetRetryAfter())
+	}
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.SetRateLimit(10, 1, time.Now().Add(time.Hour))
+	client := server.GitHubClient()
+
+	_, resp, err := client.Actions.ListArtifacts(context.Background(), "octo-org", "octo-docs", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Rate.Limit != 10 || resp.Rate.Remaining != 0 {
+		t.Errorf("unexpected rate: %+v", resp.Rate)
+	}
+
+	_, _, err = client.Actions.ListArtifacts(context.Background(), "octo-org", "octo-docs", nil)
+	var rateLimit *github.RateLimitError
+	if !errors.As(err, &rateLimit) {
+		t.Errorf("expected a rate limit error, got %v", err)
+	}
+}
+
+func TestServer_Repositories(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddRepositories("octo-org", &github.Repository{Name: github.Ptr("octo-docs")})
+	client := server.GitHubClient()
+
+	repos, _, err := client.Repositories.ListByOrg(context.Background(), "octo-org", nil)
+	if err != nil || len(repos) != 1 {
+		t.Fatalf("expected one repository, got %v (%v)", repos, err)
+	}
+
+	_, resp, err := client.Repositories.ListByOrg(context.Background(), "unknown", nil)
+	if err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown org, got %v", err)
+	}
+}
+
+func TestServer_Installations(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.AddInstallation("octo-org", 42)
+	client := server.GitHubClient()
+
+	installation, _, err := client.Apps.FindOrganizationInstallation(context.Background(), "octo-org")
+	if err != nil || installation.GetID() != 42 {
+		t.Fatalf("expected installation 42, got %v (%v)", installation, err)
+	}
+	if _, resp, err := client.Apps.FindUserInstallation(context.Background(), "octocat"); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an owner without an installation, got %v", err)
+	}
+
+	// without a bearer token, no installation token is issued
+	if _, resp, err := client.Apps.CreateInstallationToken(context.Background(), 42, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a JWT, got %v", err)
+	}
+	authenticated := server.GitHubClient().WithAuthToken("jwt")
+	token, _, err := authenticated.Apps.CreateInstallationToken(context.Background(), 42, nil)
+	if err != nil || token.GetToken() != "ghs_fake_42_1" || !token.GetExpiresAt().After(time.Now()) {
+		t.Errorf("expected an unexpired installation token, got %v (%v)", token, err)
+	}
+	if tokens := server.Tokens(); len(tokens) != 1 || tokens[0] != token.GetToken() {
+		t.Errorf("expected the issued token to be recorded, got %v", tokens)
+	}
+}
@@ -0,0 +1,208 @@
+// Package fakes3 provides an in-process fake of an S3-compatible object store for tests.
+//
+// The fake serves PUT and GET of objects in path-style buckets (/{bucket}/{key}), and rejects requests which aren't
+// signed with AWS Signature Version 4 using its credentials, as MinIO and S3 do.
+package fakes3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// AccessKeyID is the access key of the credentials accepted by the fake
+	AccessKeyID = "fakes3-access-key"
+	// SecretAccessKey is the secret key of the credentials accepted by the fake
+	SecretAccessKey = "fakes3-secret-key"
+
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// Server is a fake S3-compatible object store
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	buckets  map[string]map[string][]byte
+	requests []string
+}
+
+// NewServer starts a fake object store. Callers must Close it when done.
+func NewServer() *Server {
+	s := &Server{buckets: make(map[string]map[string][]byte)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// CreateBucket creates an empty bucket
+func (s *Server) CreateBucket(bucket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.buckets[bucket]; !ok {
+		s.buckets[bucket] = make(map[string][]byte)
+	}
+}
+
+// Object returns the content of an object
+func (s *Server) Object(bucket, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.buckets[bucket][key]
+	return content, ok
+}
+
+// Keys returns the keys of the objects of a bucket, sorted
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.buckets[bucket]))
+	for key := range s.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Requests returns the method and path of every request received
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.EscapedPath())
+	s.mu.Unlock()
+
+	if err := verifySignature(r); err != nil {
+		writeError(w, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	s.mu.Lock()
+	objects, ok := s.buckets[bucket]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		content, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(content)) != r.ContentLength {
+			writeError(w, http.StatusBadRequest, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header")
+			return
+		}
+		s.mu.Lock()
+		objects[key] = content
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		s.mu.Lock()
+		content, ok := objects[key]
+		s.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist")
+			return
+		}
+		_, _ = w.Write(content)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed")
+	}
+}
+
+// verifySignature checks the request is signed with the credentials of the fake
+func verifySignature(r *http.Request) error {
+	const prefix = "AWS4-HMAC-SHA256 "
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, prefix) {
+		return fmt.Errorf("missing AWS4-HMAC-SHA256 authorization")
+	}
+	parts := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(authorization, prefix), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		parts[k] = v
+	}
+
+	credential := strings.Split(parts["Credential"], "/")
+	if len(credential) != 5 || credential[0] != AccessKeyID || credential[3] != "s3" || credential[4] != "aws4_request" {
+		return fmt.Errorf("invalid credential %q", parts["Credential"])
+	}
+	date, region := credential[1], credential[2]
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, date) {
+		return fmt.Errorf("x-amz-date %q doesn't match the credential scope", amzDate)
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != unsignedPayload {
+		content, err := io.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != payloadHash {
+			return fmt.Errorf("x-amz-content-sha256 doesn't match the payload")
+		}
+		r.Body = io.NopCloser(strings.NewReader(string(content)))
+	}
+
+	signed := strings.Split(parts["SignedHeaders"], ";")
+	headers := make([]string, 0, len(signed))
+	for _, name := range signed {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers = append(headers, name+":"+strings.TrimSpace(value)+"\n")
+	}
+	canonical := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		strings.Join(headers, ""),
+		parts["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	scope := strings.Join(credential[1:], "/")
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + SecretAccessKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	if hex.EncodeToString(hmacSHA256(key, toSign)) != parts["Signature"] {
+		return fmt.Errorf("signature doesn't match")
+	}
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_ = xml.NewEncoder(w).Encode(errorResponse{Code: code, Message: message})
+}
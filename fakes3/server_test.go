@@ -0,0 +1,50 @@
+package fakes3
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestServer_RejectsUnsignedRequests(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	server.CreateBucket("backups")
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/backups/1.zip", strings.NewReader("content"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected an unsigned request to be forbidden, got %s", resp.Status)
+	}
+	if keys := server.Keys("backups"); len(keys) != 0 {
+		t.Errorf("expected nothing to be stored, got %v", keys)
+	}
+	if requests := server.Requests(); len(requests) != 1 || requests[0] != "PUT /backups/1.zip" {
+		t.Errorf("expected the request to be recorded, got %v", requests)
+	}
+}
+
+func TestVerifySignature_Credential(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+	}{
+		{"missing", ""},
+		{"other scheme", "Bearer token"},
+		{"other access key", "AWS4-HMAC-SHA256 Credential=other/20240101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=00"},
+		{"other service", "AWS4-HMAC-SHA256 Credential=" + AccessKeyID + "/20240101/us-east-1/ec2/aws4_request, SignedHeaders=host, Signature=00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "http://localhost/backups/1.zip", nil)
+			req.Header.Set("Authorization", tt.authorization)
+			if err := verifySignature(req); err == nil {
+				t.Error("expected the signature to be rejected")
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// appJWTLifetime is below the 10 minute maximum GitHub accepts for an app JWT
+	appJWTLifetime = 9 * time.Minute
+	// appJWTClockSkew backdates the JWT to allow for clock drift between the client and GitHub
+	appJWTClockSkew = time.Minute
+)
+
+// GitHubApp authenticates as an installation of a GitHub App, rather than with a personal access token
+type GitHubApp struct {
+	// AppID is the numeric id of the app
+	AppID int64
+	// PrivateKey is the PEM encoded private key of the app, in PKCS#1 or PKCS#8 form
+	PrivateKey []byte
+	// InstallationID is the id of the installation. When zero, the installation is looked up for Owner.
+	InstallationID int64
+	// Owner is the org or user whose installation is looked up when InstallationID is zero
+	Owner string
+}
+
+// WithGitHubApp authenticates as an installation of a GitHub App. Installation tokens are created when first needed,
+// and created again shortly before they expire.
+func WithGitHubApp(app GitHubApp) Option {
+	return func(a *App) {
+		a.githubApp = &app
+	}
+}
+
+// httpClient returns a client which authenticates every request with an installation token of the app
+func (g *GitHubApp) httpClient(ctx context.Context, newClient func(*http.Client) *github.Client) (*http.Client, error) {
+	if g.AppID <= 0 {
+		return nil, errors.New("github app id is missing")
+	}
+	if g.InstallationID <= 0 && len(g.Owner) == 0 {
+		return nil, errors.New("github app requires an installation id or an owner whose installation to look up")
+	}
+	key, err := parsePrivateKey(g.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	jwt := oauth2.ReuseTokenSource(nil, &appTokenSource{appID: g.AppID, key: key})
+	apps := newClient(oauth2.NewClient(ctx, jwt)).Apps
+	installation := &installationTokenSource{ctx: ctx, apps: apps, id: g.InstallationID, owner: g.Owner}
+	return oauth2.NewClient(ctx, oauth2.ReuseTokenSource(nil, installation)), nil
+}
+
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("github app private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse github app private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("github app private key must be an RSA key")
+	}
+	return key, nil
+}
+
+// appTokenSource creates the RS256 JWTs which authenticate as the app itself
+type appTokenSource struct {
+	appID int64
+	key   *rsa.PrivateKey
+	now   func() time.Time
+}
+
+func (s *appTokenSource) Token() (*oauth2.Token, error) {
+	now := time.Now()
+	if s.now != nil {
+		now = s.now()
+	}
+	expiry := now.Add(appJWTLifetime)
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-appJWTClockSkew).Unix(),
+		"exp": expiry.Unix(),
+		"iss": strconv.FormatInt(s.appID, 10),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign github app jwt: %w", err)
+	}
+
+	return &oauth2.Token{
+		AccessToken: unsigned + "." + base64.RawURLEncoding.EncodeToString(signature),
+		TokenType:   "Bearer",
+		Expiry:      expiry,
+	}, nil
+}
+
+// installationTokenSource creates installation tokens, looking up the installation of owner on first use
+type installationTokenSource struct {
+	ctx   context.Context
+	apps  *github.AppsService
+	owner string
+
+	mu sync.Mutex
+	id int64
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.id <= 0 {
+		id, err := s.findInstallation()
+		if err != nil {
+			return nil, err
+		}
+		s.id = id
+	}
+
+	token, _, err := s.apps.CreateInstallationToken(s.ctx, s.id, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create an installation token for installation %d: %w", s.id, err)
+	}
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		TokenType:   "Bearer",
+		Expiry:      token.GetExpiresAt().Time,
+	}, nil
+}
+
+// findInstallation looks up the installation of an org, falling back to the installation of a user
+func (s *installationTokenSource) findInstallation() (int64, error) {
+	installation, resp, err := s.apps.FindOrganizationInstallation(s.ctx, s.owner)
+	if err != nil && statusCode(resp) == http.StatusNotFound {
+		installation, _, err = s.apps.FindUserInstallation(s.ctx, s.owner)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to find the github app installation of %s: %w", s.owner, err)
+	}
+	return installation.GetID(), nil
+}
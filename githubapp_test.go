@@ -0,0 +1,169 @@
+package app
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return key
+}
+
+func encodePKCS1(key *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func TestAppTokenSource(t *testing.T) {
+	key := generateKey(t)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	source := &appTokenSource{appID: 12345, key: key, now: func() time.Time { return now }}
+
+	token, err := source.Token()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !token.Expiry.Equal(now.Add(appJWTLifetime)) {
+		t.Errorf("expected the token to expire at %v, got %v", now.Add(appJWTLifetime), token.Expiry)
+	}
+
+	parts := strings.Split(token.AccessToken, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected a JWT of three parts, got %q", token.AccessToken)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("expected a valid RS256 signature, got %v", err)
+	}
+
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	claims := struct {
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+		Iss string `json:"iss"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if claims.Iss != "12345" || claims.Iat != now.Add(-appJWTClockSkew).Unix() || claims.Exp != now.Add(appJWTLifetime).Unix() {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key := generateKey(t)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecPKCS8, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+
+	tests := []struct {
+		name    string
+		pem     []byte
+		wantErr bool
+	}{
+		{"pkcs1", encodePKCS1(key), false},
+		{"pkcs8", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), false},
+		{"not pem", []byte("not a key"), true},
+		{"not rsa", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecPKCS8}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parsePrivateKey(tt.pem)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePrivateKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !parsed.Equal(key) {
+				t.Error("expected the parsed key to equal the original")
+			}
+		})
+	}
+}
+
+func TestGitHubApp_FakeServer(t *testing.T) {
+	key := generateKey(t)
+	tests := []struct {
+		name          string
+		app           GitHubApp
+		lifetime      time.Duration
+		lookup        bool
+		atLeastTokens int
+	}{
+		{"installation id", GitHubApp{AppID: 1, InstallationID: 42}, time.Hour, false, 1},
+		{"installation of owner", GitHubApp{AppID: 1, Owner: "octo-org"}, time.Hour, true, 1},
+		// tokens within oauth2's expiry delta of their expiry are refreshed before every request
+		{"refreshed", GitHubApp{AppID: 1, InstallationID: 42}, time.Second, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakegithub.NewServer()
+			defer server.Close()
+			server.AddInstallation("octo-org", 42)
+			server.SetTokenLifetime(tt.lifetime)
+			server.AddArtifacts("octo-org", "octo-docs", createServedArtifact(1, "a", 1000, 1), createServedArtifact(2, "b", 1000, 1))
+
+			tt.app.PrivateKey = encodePKCS1(key)
+			httpClient, err := tt.app.httpClient(context.Background(), server.GitHubClientWith)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			app := newServedApp(t, server, WithClient(server.GitHubClientWith(httpClient)), WithRepository("octo-org", "octo-docs"))
+			if _, err := app.Run(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if deleted := server.Deleted("octo-org", "octo-docs"); len(deleted) != 2 {
+				t.Errorf("expected both artifacts to be deleted, got %v", deleted)
+			}
+			tokens := server.Tokens()
+			if len(tokens) < tt.atLeastTokens {
+				t.Errorf("expected at least %d installation tokens, got %v", tt.atLeastTokens, tokens)
+			}
+			requests := server.Requests()
+			if looked := requests[0] == "GET /orgs/octo-org/installation"; looked != tt.lookup {
+				t.Errorf("expected installation lookup %v, got requests %v", tt.lookup, requests)
+			}
+			for i, authorization := range server.Authorizations() {
+				if strings.Contains(requests[i], "/actions/") && !strings.HasPrefix(authorization, "Bearer ghs_fake_42_") {
+					t.Errorf("expected %s to use an installation token, got %q", requests[i], authorization)
+				}
+			}
+		})
+	}
+}
+
+func TestGitHubApp_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		app  GitHubApp
+	}{
+		{"missing app id", GitHubApp{InstallationID: 1, PrivateKey: []byte("x")}},
+		{"missing installation", GitHubApp{AppID: 1, PrivateKey: []byte("x")}},
+		{"invalid key", GitHubApp{AppID: 1, InstallationID: 1, PrivateKey: []byte("x")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewWithOptions(WithGitHubApp(tt.app)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
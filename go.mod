@@ -4,13 +4,13 @@ go 1.25
 
 require (
 	github.com/alecthomas/kong v1.13.0
-	github.com/google/go-github/v32 v32.1.0
+	github.com/google/go-github/v75 v75.0.0
 	github.com/sirupsen/logrus v1.9.4
 	golang.org/x/oauth2 v0.34.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/google/go-querystring v1.2.0 // indirect
-	golang.org/x/crypto v0.47.0 // indirect
 	golang.org/x/sys v0.40.0 // indirect
 )
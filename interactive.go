@@ -0,0 +1,316 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+const reasonNotConfirmed = "not confirmed"
+
+// Selector lets a person review the artifacts selected for deletion from a repository, and returns those to delete.
+// Artifacts it doesn't return are reported as skipped.
+type Selector interface {
+	Select(ctx context.Context, owner, repo string, candidates []*github.Artifact) ([]*github.Artifact, error)
+}
+
+// WithSelector confirms the artifacts selected for deletion with selector before deleting any of them
+func WithSelector(selector Selector) Option {
+	return func(a *App) {
+		a.selector = selector
+	}
+}
+
+// confirmDeletions asks the Selector which of the selected artifacts to delete, skipping the others. Without a
+// Selector, every selected artifact is deleted.
+func (a *App) confirmDeletions(ctx context.Context, selected []*github.Artifact, report *Report) ([]*github.Artifact, error) {
+	if a.selector == nil || len(selected) == 0 {
+		return selected, nil
+	}
+
+	chosen, err := a.selector.Select(ctx, *a.Owner, *a.Repo, selected)
+	if err != nil {
+		reason := reasonNotConfirmed
+		if ctx.Err() != nil {
+			reason = reasonCancelled
+		}
+		for _, artifact := range selected {
+			report.skipped(artifact, reason)
+		}
+		return nil, err
+	}
+
+	confirmed := make(map[int64]bool, len(chosen))
+	for _, artifact := range chosen {
+		confirmed[artifact.GetID()] = true
+	}
+	kept := make([]*github.Artifact, 0, len(chosen))
+	for _, artifact := range selected {
+		if confirmed[artifact.GetID()] {
+			kept = append(kept, artifact)
+			continue
+		}
+		report.skipped(artifact, reasonNotConfirmed)
+	}
+	a.log().WithFields(log.Fields{"selected": len(selected), "confirmed": len(kept)}).Info("Deletions confirmed.")
+	return kept, nil
+}
+
+// PromptSelector reviews the artifacts selected for deletion on a terminal. As a table, the artifacts can be sorted
+// and toggled before confirming; otherwise they are listed, and deleted only when confirmed with y. Reviews of
+// several repositories are asked one at a time, and reaching the end of the input deletes nothing.
+type PromptSelector struct {
+	in    io.Reader
+	out   io.Writer
+	table bool
+
+	mu    sync.Mutex
+	once  sync.Once
+	lines chan string
+}
+
+// NewPromptSelector creates a PromptSelector reading answers from in and writing prompts to out. table shows a
+// sortable table of the artifacts in which to toggle them, rather than a y/N prompt.
+func NewPromptSelector(in io.Reader, out io.Writer, table bool) *PromptSelector {
+	return &PromptSelector{in: in, out: out, table: table}
+}
+
+// Select asks which artifacts of owner/repo to delete
+func (p *PromptSelector) Select(ctx context.Context, owner, repo string, candidates []*github.Artifact) ([]*github.Artifact, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.table {
+		return p.selectTable(ctx, owner+"/"+repo, candidates)
+	}
+	return p.confirm(ctx, owner+"/"+repo, candidates)
+}
+
+// confirm lists the candidates and deletes all or none of them
+func (p *PromptSelector) confirm(ctx context.Context, name string, candidates []*github.Artifact) ([]*github.Artifact, error) {
+	for _, artifact := range candidates {
+		_, _ = fmt.Fprintf(p.out, "  %s (%d, %s)\n", artifact.GetName(), artifact.GetID(), formatBytes(artifact.GetSizeInBytes()))
+	}
+	_, _ = fmt.Fprintf(p.out, "%s: delete %d artifacts (%s)? [y/N]: ", name, len(candidates), formatBytes(totalBytes(candidates)))
+	answer, err := p.readLine(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return candidates, nil
+	default:
+		return nil, nil
+	}
+}
+
+// selectTable shows the candidates as a table until the selection is confirmed or abandoned
+func (p *PromptSelector) selectTable(ctx context.Context, name string, candidates []*github.Artifact) ([]*github.Artifact, error) {
+	s := &selection{artifacts: append([]*github.Artifact(nil), candidates...), selected: make(map[int64]bool)}
+	s.setAll(true)
+	for {
+		s.render(p.out, name, time.Now())
+		_, _ = fmt.Fprint(p.out, "Toggle numbers or ranges (1 3-5), [a]ll, [n]one, [s]ort name|size|age|run, [y]es to delete, [q]uit: ")
+		answer, err := p.readLine(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		fields := strings.Fields(strings.ToLower(answer))
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "y", "yes":
+			return s.chosen(), nil
+		case "q", "quit":
+			return nil, nil
+		case "a", "all":
+			s.setAll(true)
+		case "n", "none":
+			s.setAll(false)
+		case "s", "sort":
+			if len(fields) != 2 || !s.sortBy(fields[1]) {
+				_, _ = fmt.Fprintln(p.out, "Sort by name, size, age or run.")
+			}
+		default:
+			if err := s.toggle(fields); err != nil {
+				_, _ = fmt.Fprintln(p.out, err)
+			}
+		}
+	}
+}
+
+// readLine reads the next line of input, unless ctx is done first. The end of the input answers q, deleting nothing.
+func (p *PromptSelector) readLine(ctx context.Context) (string, error) {
+	p.once.Do(func() {
+		// input is read in the background, since reads can't be interrupted when ctx is cancelled
+		p.lines = make(chan string)
+		go func() {
+			defer close(p.lines)
+			scanner := bufio.NewScanner(p.in)
+			for scanner.Scan() {
+				p.lines <- scanner.Text()
+			}
+		}()
+	})
+
+	select {
+	case line, ok := <-p.lines:
+		if !ok {
+			_, _ = fmt.Fprintln(p.out)
+			return "q", nil
+		}
+		return strings.TrimSpace(line), nil
+	case <-ctx.Done():
+		_, _ = fmt.Fprintln(p.out)
+		return "", ctx.Err()
+	}
+}
+
+// selection is the state of the table of a PromptSelector
+type selection struct {
+	artifacts  []*github.Artifact
+	selected   map[int64]bool
+	sortKey    string
+	descending bool
+}
+
+func (s *selection) setAll(selected bool) {
+	for _, artifact := range s.artifacts {
+		s.selected[artifact.GetID()] = selected
+	}
+}
+
+// chosen returns the selected artifacts in the order shown
+func (s *selection) chosen() []*github.Artifact {
+	chosen := make([]*github.Artifact, 0, len(s.artifacts))
+	for _, artifact := range s.artifacts {
+		if s.selected[artifact.GetID()] {
+			chosen = append(chosen, artifact)
+		}
+	}
+	return chosen
+}
+
+// sortBy sorts the table by a column, reversing the order when sorted by the same column again
+func (s *selection) sortBy(key string) bool {
+	var less func(a, b *github.Artifact) bool
+	switch key {
+	case "name":
+		less = func(a, b *github.Artifact) bool { return a.GetName() < b.GetName() }
+	case "size":
+		less = func(a, b *github.Artifact) bool { return a.GetSizeInBytes() < b.GetSizeInBytes() }
+	case "age":
+		// the oldest artifacts first
+		less = func(a, b *github.Artifact) bool { return a.GetCreatedAt().Before(b.GetCreatedAt().Time) }
+	case "run":
+		less = func(a, b *github.Artifact) bool { return a.GetWorkflowRun().GetID() < b.GetWorkflowRun().GetID() }
+	default:
+		return false
+	}
+
+	s.descending = key == s.sortKey && !s.descending
+	s.sortKey = key
+	sort.SliceStable(s.artifacts, func(i, j int) bool {
+		if s.descending {
+			return less(s.artifacts[j], s.artifacts[i])
+		}
+		return less(s.artifacts[i], s.artifacts[j])
+	})
+	return true
+}
+
+// toggle flips the selection of the artifacts at the numbers or ranges of numbers shown in the table
+func (s *selection) toggle(fields []string) error {
+	indexes := make([]int, 0)
+	for _, field := range fields {
+		from, to, isRange := strings.Cut(field, "-")
+		first, err := strconv.Atoi(from)
+		last := first
+		if err == nil && isRange {
+			last, err = strconv.Atoi(to)
+		}
+		if err != nil || first < 1 || last > len(s.artifacts) || first > last {
+			return fmt.Errorf("%q is not a number or range of numbers from 1 to %d", field, len(s.artifacts))
+		}
+		for i := first; i <= last; i++ {
+			indexes = append(indexes, i-1)
+		}
+	}
+	for _, i := range indexes {
+		id := s.artifacts[i].GetID()
+		s.selected[id] = !s.selected[id]
+	}
+	return nil
+}
+
+func (s *selection) render(w io.Writer, name string, now time.Time) {
+	chosen := s.chosen()
+	_, _ = fmt.Fprintf(w, "\n%s: %d of %d artifacts selected for deletion (%s)\n", name, len(chosen), len(s.artifacts), formatBytes(totalBytes(chosen)))
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "#\t\tNAME\tSIZE\tAGE\tRUN")
+	for i, artifact := range s.artifacts {
+		mark := "[ ]"
+		if s.selected[artifact.GetID()] {
+			mark = "[x]"
+		}
+		_, _ = fmt.Fprintf(writer, "%d\t%s\t%s\t%s\t%s\t%d\n", i+1, mark, artifact.GetName(), formatBytes(artifact.GetSizeInBytes()),
+			formatAge(now.Sub(artifact.GetCreatedAt().Time)), artifact.GetWorkflowRun().GetID())
+	}
+	_ = writer.Flush()
+}
+
+func totalBytes(artifacts []*github.Artifact) int64 {
+	var total int64
+	for _, artifact := range artifacts {
+		total += artifact.GetSizeInBytes()
+	}
+	return total
+}
+
+// formatBytes formats a size in decimal units, as the size filters are given
+func formatBytes(size int64) string {
+	units := []string{"kB", "MB", "GB", "TB"}
+	if size < 1000 {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size)
+	unit := ""
+	for _, u := range units {
+		value /= 1000
+		unit = u
+		if value < 1000 {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f %s", value, unit)
+}
+
+// formatAge formats an age in days, hours and minutes, at the precision of its two largest units
+func formatAge(age time.Duration) string {
+	if age < 0 {
+		age = 0
+	}
+	days := int(age / (24 * time.Hour))
+	hours := int(age % (24 * time.Hour) / time.Hour)
+	minutes := int(age % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
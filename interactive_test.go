@@ -0,0 +1,177 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+func interactiveCandidates() []*github.Artifact {
+	now := time.Now()
+	candidates := []*github.Artifact{
+		createServedArtifact(1, "coverage", 3000, 30),
+		createServedArtifact(2, "dist", 1000, 10),
+		createServedArtifact(3, "bundle", 2000, 20),
+	}
+	for i, artifact := range candidates {
+		artifact.CreatedAt = &github.Timestamp{Time: now.Add(-time.Duration(i+1) * time.Hour)}
+	}
+	return candidates
+}
+
+func TestPromptSelector_Table(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []int64
+	}{
+		{"confirm all", "y\n", []int64{1, 2, 3}},
+		{"toggle one", "2\ny\n", []int64{1, 3}},
+		{"toggle range", "1-2\nyes\n", []int64{3}},
+		{"none then one", "n\n3\ny\n", []int64{3}},
+		{"sorted by size", "s size\n1\ny\n", []int64{3, 1}},
+		{"sorted by size descending", "s size\ns size\n1\ny\n", []int64{3, 2}},
+		{"sorted by run", "s run\ny\n", []int64{2, 3, 1}},
+		{"invalid input is ignored", "7\nx-y\ns color\n2\ny\n", []int64{1, 3}},
+		{"quit", "q\n", nil},
+		{"end of input", "2\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			selector := NewPromptSelector(strings.NewReader(tt.input), out, true)
+			chosen, err := selector.Select(context.Background(), "octo-org", "octo-docs", interactiveCandidates())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			ids := make([]int64, 0, len(chosen))
+			for _, artifact := range chosen {
+				ids = append(ids, artifact.GetID())
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("expected %v to be chosen, got %v", tt.want, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("expected %v to be chosen, got %v", tt.want, ids)
+				}
+			}
+			if !strings.Contains(out.String(), "octo-org/octo-docs: 3 of 3 artifacts selected for deletion (6.0 kB)") {
+				t.Errorf("expected the table to be shown, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestPromptSelector_Confirm(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"y\n", 3},
+		{"YES\n", 3},
+		{"n\n", 0},
+		{"\n", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			selector := NewPromptSelector(strings.NewReader(tt.input), out, false)
+			chosen, err := selector.Select(context.Background(), "octo-org", "octo-docs", interactiveCandidates())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(chosen) != tt.want {
+				t.Errorf("expected %d artifacts to be chosen, got %d", tt.want, len(chosen))
+			}
+			if !strings.Contains(out.String(), "octo-org/octo-docs: delete 3 artifacts (6.0 kB)? [y/N]: ") {
+				t.Errorf("expected a y/N prompt, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestPromptSelector_Cancelled(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer func() { _ = writer.Close() }()
+	selector := NewPromptSelector(reader, &bytes.Buffer{}, true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := selector.Select(ctx, "octo-org", "octo-docs", interactiveCandidates()); err != context.Canceled {
+		t.Errorf("expected the context's error while waiting for input, got %v", err)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{0, "0 B"},
+		{999, "999 B"},
+		{1500, "1.5 kB"},
+		{50000000, "50.0 MB"},
+		{2500000000, "2.5 GB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.size); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{-time.Minute, "0m"},
+		{42 * time.Minute, "42m"},
+		{5*time.Hour + 12*time.Minute, "5h12m"},
+		{76 * time.Hour, "3d4h"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.age); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestRun_Interactive(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", interactiveCandidates()...)
+
+	out := &bytes.Buffer{}
+	selector := NewPromptSelector(strings.NewReader("s name\n1\ny\n"), out, true)
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"), WithSelector(selector))
+	app.MinBytes = 0
+	report, err := app.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// bundle is first by name, and was deselected
+	deleted := server.Deleted("octo-org", "octo-docs")
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i] < deleted[j] })
+	if len(deleted) != 2 || deleted[0] != 1 || deleted[1] != 2 {
+		t.Errorf("expected the confirmed artifacts to be deleted, got %v", deleted)
+	}
+	if report.Matched != 3 || report.Deleted != 2 || report.Skipped != 1 {
+		t.Errorf("expected 3 matched, 2 deleted and 1 skipped, got %+v", report)
+	}
+	for _, outcome := range report.Outcomes {
+		if outcome.Status == StatusSkipped && (outcome.Artifact.GetID() != 3 || outcome.Reason != reasonNotConfirmed) {
+			t.Errorf("expected only the deselected artifact to be skipped, got %+v", outcome)
+		}
+	}
+}
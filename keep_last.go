@@ -0,0 +1,59 @@
+package app
+
+import (
+	"sort"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// retainNewest removes the newest KeepLast artifacts of each group from the candidates slated for deletion.
+// Groups are computed from the full listing so artifacts excluded by other filters still count toward the retained set.
+func (a *App) retainNewest(listed []*github.Artifact, candidates []*github.Artifact) []*github.Artifact {
+	if a.KeepLast <= 0 {
+		return candidates
+	}
+
+	sorted := make([]*github.Artifact, 0, len(listed))
+	for _, artifact := range listed {
+		// expired artifacts no longer hold any data, so they can't count toward what is kept
+		if !artifact.GetExpired() {
+			sorted = append(sorted, artifact)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ti, tj := sorted[i].GetCreatedAt().Time, sorted[j].GetCreatedAt().Time
+		if ti.Equal(tj) {
+			return sorted[i].GetID() > sorted[j].GetID()
+		}
+		return ti.After(tj)
+	})
+
+	counts := make(map[string]int)
+	retained := make(map[int64]bool)
+	for _, artifact := range sorted {
+		key := a.keepLastGroup(artifact)
+		if counts[key] < a.KeepLast {
+			counts[key]++
+			retained[artifact.GetID()] = true
+		}
+	}
+
+	filtered := make([]*github.Artifact, 0, len(candidates))
+	for _, artifact := range candidates {
+		if retained[artifact.GetID()] {
+			a.log().WithFields(log.Fields{"name": artifact.GetName(), "id": artifact.GetID(), "group": a.keepLastGroup(artifact)}).
+				Debug("KeepLast: retaining one of the newest artifacts in its group.")
+			continue
+		}
+		filtered = append(filtered, artifact)
+	}
+	return filtered
+}
+
+func (a *App) keepLastGroup(artifact *github.Artifact) string {
+	if a.KeepLastByBranch {
+		return artifact.GetName() + "@" + artifact.GetWorkflowRun().GetHeadBranch()
+	}
+	return artifact.GetName()
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// Helper to create a test artifact with an id and the head branch of its workflow run
+func createRunArtifact(id int64, name string, branch string, createdAt time.Time) *github.Artifact {
+	artifact := createArtifact(name, 100, createdAt)
+	artifact.ID = &id
+	artifact.WorkflowRun = &github.ArtifactWorkflowRun{HeadBranch: &branch}
+	return artifact
+}
+
+func ids(artifacts []*github.Artifact) map[int64]bool {
+	result := make(map[int64]bool, len(artifacts))
+	for _, a := range artifacts {
+		result[a.GetID()] = true
+	}
+	return result
+}
+
+func TestRetainNewest(t *testing.T) {
+	now := time.Now()
+	listed := []*github.Artifact{
+		createRunArtifact(1, "coverage", "main", now.Add(-5*time.Hour)),
+		createRunArtifact(2, "coverage", "main", now.Add(-4*time.Hour)),
+		createRunArtifact(3, "coverage", "feature", now.Add(-3*time.Hour)),
+		createRunArtifact(4, "coverage", "main", now.Add(-2*time.Hour)),
+		createRunArtifact(5, "dist", "main", now.Add(-6*time.Hour)),
+		createRunArtifact(6, "dist", "main", now.Add(-1*time.Hour)),
+	}
+
+	tests := []struct {
+		name     string
+		keepLast int
+		byBranch bool
+		want     []int64
+	}{
+		{
+			name:     "disabled - all candidates remain",
+			keepLast: 0,
+			want:     []int64{1, 2, 3, 4, 5, 6},
+		},
+		{
+			name:     "keep newest of each name",
+			keepLast: 1,
+			want:     []int64{1, 2, 3, 5},
+		},
+		{
+			name:     "keep newest two of each name",
+			keepLast: 2,
+			want:     []int64{1, 2},
+		},
+		{
+			name:     "keep newest of each name and branch",
+			keepLast: 1,
+			byBranch: true,
+			want:     []int64{1, 2, 5},
+		},
+		{
+			name:     "N exceeds group sizes - nothing deleted",
+			keepLast: 10,
+			want:     []int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{KeepLast: tt.keepLast, KeepLastByBranch: tt.byBranch}
+			result := ids(app.retainNewest(listed, listed))
+			if len(result) != len(tt.want) {
+				t.Fatalf("expected %d artifacts, got %d", len(tt.want), len(result))
+			}
+			for _, id := range tt.want {
+				if !result[id] {
+					t.Errorf("expected artifact %d to be slated for deletion", id)
+				}
+			}
+		})
+	}
+}
+
+func TestRetainNewest_GroupsFromFullListing(t *testing.T) {
+	now := time.Now()
+	newest := createRunArtifact(2, "coverage", "main", now.Add(-1*time.Hour))
+	older := createRunArtifact(1, "coverage", "main", now.Add(-2*time.Hour))
+
+	// the newest artifact didn't pass the other filters, but still counts toward those retained
+	app := &App{KeepLast: 1}
+	result := app.retainNewest([]*github.Artifact{older, newest}, []*github.Artifact{older})
+	if len(result) != 1 || result[0].GetID() != 1 {
+		t.Errorf("expected the older artifact to remain slated for deletion")
+	}
+}
+
+func TestRetainNewest_IgnoresExpired(t *testing.T) {
+	now := time.Now()
+	expired := createRunArtifact(2, "coverage", "main", now.Add(-1*time.Hour))
+	expired.Expired = github.Ptr(true)
+	older := createRunArtifact(1, "coverage", "main", now.Add(-2*time.Hour))
+
+	app := &App{KeepLast: 1}
+	result := app.retainNewest([]*github.Artifact{older, expired}, []*github.Artifact{older, expired})
+	if len(result) != 1 || result[0].GetID() != 2 {
+		t.Errorf("expected only the expired artifact to remain slated for deletion")
+	}
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxDeleteAbort aborts a run selecting more than MaxDelete artifacts or MaxDeleteBytes bytes, before deleting any
+	MaxDeleteAbort = "abort"
+	// MaxDeleteOldest deletes the oldest selected artifacts up to MaxDelete and MaxDeleteBytes, and skips the rest
+	MaxDeleteOldest = "oldest"
+	// MaxDeleteLargest deletes the largest selected artifacts up to MaxDelete and MaxDeleteBytes, and skips the rest
+	MaxDeleteLargest = "largest"
+)
+
+const (
+	reasonAborted   = "aborted, over the deletion cap"
+	reasonOverLimit = "over the deletion cap"
+)
+
+// MaxDeleteError is returned when the artifacts selected for deletion exceed MaxDelete or MaxDeleteBytes, and the run
+// was aborted before deleting any of them
+type MaxDeleteError struct {
+	Owner          string
+	Repo           string
+	Selected       int
+	Bytes          int64
+	MaxDelete      int
+	MaxDeleteBytes int64
+}
+
+func (e *MaxDeleteError) Error() string {
+	caps := make([]string, 0, 2)
+	if e.MaxDelete > 0 {
+		caps = append(caps, fmt.Sprintf("max-delete of %d artifacts", e.MaxDelete))
+	}
+	if e.MaxDeleteBytes > 0 {
+		caps = append(caps, fmt.Sprintf("max-delete-bytes of %d bytes", e.MaxDeleteBytes))
+	}
+	return fmt.Sprintf("%d artifacts (%d bytes) of %s/%s would have been deleted, exceeding the %s; nothing was deleted. "+
+		"Check the filters, raise the cap, or set max-delete-order to %s or %s to delete only up to the cap",
+		e.Selected, e.Bytes, e.Owner, e.Repo, strings.Join(caps, " and "), MaxDeleteOldest, MaxDeleteLargest)
+}
+
+// limited is true when MaxDelete or MaxDeleteBytes caps the deletions of a run
+func (a *App) limited() bool {
+	return a.MaxDelete > 0 || a.MaxDeleteBytes > 0
+}
+
+func (a *App) maxDeleteOrder() string {
+	if len(a.MaxDeleteOrder) == 0 {
+		return MaxDeleteAbort
+	}
+	return a.MaxDeleteOrder
+}
+
+// limitDeletions enforces MaxDelete and MaxDeleteBytes on the artifacts selected for deletion. Over the caps, every
+// artifact is skipped and a *MaxDeleteError returned, unless MaxDeleteOrder selects which artifacts to delete up to
+// the caps; the others are then skipped.
+func (a *App) limitDeletions(selected []*github.Artifact, report *Report) ([]*github.Artifact, error) {
+	if !a.limited() {
+		return selected, nil
+	}
+
+	var total int64
+	for _, artifact := range selected {
+		total += artifact.GetSizeInBytes()
+	}
+	if a.withinLimits(len(selected), total) {
+		return selected, nil
+	}
+
+	fields := log.Fields{"count": len(selected), "bytes": total, "maxDelete": a.MaxDelete, "maxDeleteBytes": a.MaxDeleteBytes, "order": a.maxDeleteOrder()}
+	if a.maxDeleteOrder() == MaxDeleteAbort {
+		a.log().WithFields(fields).Error("Artifacts selected for deletion exceed the deletion cap. Nothing will be deleted.")
+		for _, artifact := range selected {
+			report.skipped(artifact, reasonAborted)
+		}
+		return nil, &MaxDeleteError{Owner: *a.Owner, Repo: *a.Repo, Selected: len(selected), Bytes: total, MaxDelete: a.MaxDelete, MaxDeleteBytes: a.MaxDeleteBytes}
+	}
+
+	ordered := append([]*github.Artifact(nil), selected...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if a.MaxDeleteOrder == MaxDeleteLargest {
+			return ordered[i].GetSizeInBytes() > ordered[j].GetSizeInBytes()
+		}
+		return ordered[i].GetCreatedAt().Before(ordered[j].GetCreatedAt().Time)
+	})
+
+	var bytes int64
+	count := 0
+	for count < len(ordered) && a.withinLimits(count+1, bytes+ordered[count].GetSizeInBytes()) {
+		bytes += ordered[count].GetSizeInBytes()
+		count++
+	}
+	for _, artifact := range ordered[count:] {
+		report.skipped(artifact, reasonOverLimit)
+	}
+	a.log().WithFields(fields).WithFields(log.Fields{"deleting": count, "deletingBytes": bytes}).
+		Warn("Artifacts selected for deletion exceed the deletion cap. Only those up to the cap will be deleted.")
+	return ordered[:count], nil
+}
+
+func (a *App) withinLimits(count int, bytes int64) bool {
+	if a.MaxDelete > 0 && count > a.MaxDelete {
+		return false
+	}
+	if a.MaxDeleteBytes > 0 && bytes > a.MaxDeleteBytes {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+func TestLimitDeletions(t *testing.T) {
+	now := time.Now()
+	selected := []*github.Artifact{
+		createArtifact("b", 300, now.Add(-2*time.Hour)),
+		createArtifact("a", 100, now.Add(-3*time.Hour)),
+		createArtifact("c", 200, now.Add(-1*time.Hour)),
+	}
+
+	tests := []struct {
+		name      string
+		maxDelete int
+		maxBytes  int64
+		order     string
+		want      []string
+		wantErr   bool
+	}{
+		{"uncapped", 0, 0, "", []string{"b", "a", "c"}, false},
+		{"within caps", 3, 600, "", []string{"b", "a", "c"}, false},
+		{"count aborts", 2, 0, "", nil, true},
+		{"bytes abort", 0, 500, MaxDeleteAbort, nil, true},
+		{"oldest by count", 2, 0, MaxDeleteOldest, []string{"a", "b"}, false},
+		{"largest by count", 2, 0, MaxDeleteLargest, []string{"b", "c"}, false},
+		{"oldest by bytes", 0, 450, MaxDeleteOldest, []string{"a", "b"}, false},
+		{"largest by bytes", 0, 450, MaxDeleteLargest, []string{"b"}, false},
+		{"both caps", 2, 250, MaxDeleteOldest, []string{"a"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{Owner: github.Ptr("octo-org"), Repo: github.Ptr("octo-docs"), MaxDelete: tt.maxDelete, MaxDeleteBytes: tt.maxBytes, MaxDeleteOrder: tt.order, logger: quietLogger()}
+			report := newReport("octo-org", "octo-docs")
+			result, err := app.limitDeletions(selected, report)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("limitDeletions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var capped *MaxDeleteError
+				if !errors.As(err, &capped) || capped.Selected != 3 || capped.Bytes != 600 {
+					t.Errorf("expected a MaxDeleteError of 3 artifacts and 600 bytes, got %v", err)
+				}
+				if report.Skipped != len(selected) {
+					t.Errorf("expected every artifact to be skipped, got %+v", report)
+				}
+				return
+			}
+
+			names := make([]string, 0, len(result))
+			for _, artifact := range result {
+				names = append(names, artifact.GetName())
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v to be deleted, got %v", tt.want, names)
+			}
+			if report.Skipped != len(selected)-len(result) {
+				t.Errorf("expected the artifacts over the cap to be skipped, got %+v", report)
+			}
+		})
+	}
+}
+
+func TestMaxDeleteError(t *testing.T) {
+	err := &MaxDeleteError{Owner: "octo-org", Repo: "octo-docs", Selected: 250, Bytes: 5000, MaxDelete: 100, MaxDeleteBytes: 1000}
+	want := "250 artifacts (5000 bytes) of octo-org/octo-docs would have been deleted, exceeding the max-delete of 100 artifacts and max-delete-bytes of 1000 bytes"
+	if !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("expected the error to start with %q, got %q", want, err.Error())
+	}
+}
+
+func TestRun_MaxDelete(t *testing.T) {
+	tests := []struct {
+		name        string
+		order       string
+		wantDeleted []int64
+		wantErr     bool
+	}{
+		{"abort", MaxDeleteAbort, nil, true},
+		{"oldest", MaxDeleteOldest, []int64{1, 2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakegithub.NewServer()
+			defer server.Close()
+			for i := int64(1); i <= 5; i++ {
+				artifact := createServedArtifact(i, "dist", 100, 1)
+				artifact.CreatedAt = &github.Timestamp{Time: time.Now().Add(-time.Duration(10-i) * time.Hour)}
+				server.AddArtifacts("octo-org", "octo-docs", artifact)
+			}
+
+			app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+			app.MinBytes = 0
+			app.MaxDelete = 2
+			app.MaxDeleteOrder = tt.order
+			report, err := app.Run(context.Background())
+			var capped *MaxDeleteError
+			if tt.wantErr != errors.As(err, &capped) {
+				t.Fatalf("expected a MaxDeleteError %v, got %v", tt.wantErr, err)
+			}
+
+			deleted := server.Deleted("octo-org", "octo-docs")
+			sort.Slice(deleted, func(i, j int) bool { return deleted[i] < deleted[j] })
+			if len(deleted) != len(tt.wantDeleted) {
+				t.Fatalf("expected %v to be deleted, got %v", tt.wantDeleted, deleted)
+			}
+			for i := range deleted {
+				if deleted[i] != tt.wantDeleted[i] {
+					t.Fatalf("expected %v to be deleted, got %v", tt.wantDeleted, deleted)
+				}
+			}
+			if report.Matched != 5 || report.Skipped != 5-len(tt.wantDeleted) {
+				t.Errorf("expected 5 matched and the rest skipped, got %+v", report)
+			}
+		})
+	}
+}
+
+func TestRun_MaxDeletePreconditions(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(app *App)
+	}{
+		{"negative count", func(app *App) { app.MaxDelete = -1 }},
+		{"negative bytes", func(app *App) { app.MaxDeleteBytes = -1 }},
+		{"unknown order", func(app *App) { app.MaxDelete = 1; app.MaxDeleteOrder = "newest" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := NewWithOptions(WithArtifactService(&fakeArtifactService{}), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+			tt.apply(app)
+			if _, err := app.Run(context.Background()); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestApply_MaxDelete(t *testing.T) {
+	service := &fakeArtifactService{artifacts: []*github.Artifact{
+		createServedArtifact(1, "coverage", 1000, 10),
+		createServedArtifact(2, "coverage", 1000, 10),
+	}, perPage: 10}
+	planner, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+	planner.ActiveDuration = "30m"
+	plan, err := planner.Plan(context.Background())
+	if err != nil || len(plan.Artifacts) != 2 {
+		t.Fatalf("expected two planned artifacts, got %v (%v)", plan, err)
+	}
+
+	applier, _ := NewWithOptions(WithArtifactService(service), WithLogger(quietLogger()))
+	applier.MaxDeleteBytes = 1500
+	report, err := applier.Apply(context.Background(), plan)
+	var capped *MaxDeleteError
+	if !errors.As(err, &capped) {
+		t.Fatalf("expected a MaxDeleteError, got %v", err)
+	}
+	if len(service.deleted) != 0 || report.Skipped != 2 || report.Outcomes[0].Reason != reasonAborted {
+		t.Errorf("expected the plan to be aborted, got %+v", report)
+	}
+}
@@ -0,0 +1,22 @@
+package app
+
+import (
+	"context"
+)
+
+// reasonListed is reported for the artifacts selected by List
+const reasonListed = "listed"
+
+// List reports the artifacts which Run would delete, without deleting, archiving or prompting for anything. Artifacts
+// are selected exactly as Run selects them, including for an Org, and each is reported as skipped with the reason
+// "listed". Deletion caps don't apply, so that every selected artifact is listed. The App itself isn't modified.
+func (a *App) List(ctx context.Context) (*Report, error) {
+	list := *a
+	list.DryRun = true
+	list.listing = true
+	list.selector = nil
+	list.archive = nil
+	list.MaxDelete = 0
+	list.MaxDeleteBytes = 0
+	return list.Run(ctx)
+}
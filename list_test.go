@@ -0,0 +1,71 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+func TestList(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs",
+		createServedArtifact(1, "dist", 100, 1),
+		createServedArtifact(2, "dist", 100, 1),
+		createServedArtifact(3, "logs", 10, 1),
+	)
+
+	// flags which would otherwise delete, archive or prompt are ignored
+	archive := t.TempDir()
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"), WithArchiveStore(&DirStore{Dir: archive}),
+		WithSelector(NewPromptSelector(strings.NewReader("q\n"), &strings.Builder{}, false)))
+	app.MinBytes = 50
+	app.MaxDelete = 1
+
+	report, err := app.List(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted := server.Deleted("octo-org", "octo-docs"); len(deleted) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v", deleted)
+	}
+	if report.Matched != 2 || report.Skipped != 2 {
+		t.Errorf("expected both matching artifacts to be listed, got %+v", report)
+	}
+	for _, outcome := range report.Outcomes {
+		if outcome.Reason != reasonListed {
+			t.Errorf("expected artifact %d to be listed, got %q", outcome.Artifact.GetID(), outcome.Reason)
+		}
+	}
+	if report.Result() != ResultSuccess {
+		t.Errorf("expected a successful result, got %v", report.Result())
+	}
+
+	// the App is unchanged, so a later Run still deletes within its cap
+	if app.DryRun || app.listing || app.MaxDelete != 1 || app.archive == nil {
+		t.Errorf("expected List to leave the App unchanged")
+	}
+}
+
+func TestList_Org(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddRepositories("octo-org", createRepository("api", "private", false), createRepository("web", "public", false))
+	server.AddArtifacts("octo-org", "api", createServedArtifact(1, "a", 100, 1))
+	server.AddArtifacts("octo-org", "web", createServedArtifact(2, "a", 100, 1))
+
+	app := newServedApp(t, server)
+	app.Org = "octo-org"
+	report, err := app.List(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Matched != 2 || len(report.Repositories) != 2 {
+		t.Errorf("expected the artifacts of both repositories to be listed, got %+v", report)
+	}
+	if len(server.Deleted("octo-org", "api")) != 0 || len(server.Deleted("octo-org", "web")) != 0 {
+		t.Error("expected nothing to be deleted")
+	}
+}
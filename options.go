@@ -0,0 +1,183 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// ArtifactService is the subset of the GitHub Actions API used to list, verify and delete artifacts.
+// It is satisfied by the Actions service of a *github.Client.
+type ArtifactService interface {
+	ListArtifacts(ctx context.Context, owner, repo string, opts *github.ListArtifactsOptions) (*github.ArtifactList, *github.Response, error)
+	ListWorkflowRunArtifacts(ctx context.Context, owner, repo string, runID int64, opts *github.ListOptions) (*github.ArtifactList, *github.Response, error)
+	GetArtifact(ctx context.Context, owner, repo string, artifactID int64) (*github.Artifact, *github.Response, error)
+	DeleteArtifact(ctx context.Context, owner, repo string, artifactID int64) (*github.Response, error)
+}
+
+// RepositoryService is the subset of the GitHub Repositories API used to enumerate the repositories of an org or user.
+// It is satisfied by the Repositories service of a *github.Client.
+type RepositoryService interface {
+	ListByOrg(ctx context.Context, org string, opts *github.RepositoryListByOrgOptions) ([]*github.Repository, *github.Response, error)
+	ListByUser(ctx context.Context, user string, opts *github.RepositoryListByUserOptions) ([]*github.Repository, *github.Response, error)
+}
+
+// Option configures an App constructed via NewWithOptions
+type Option func(a *App)
+
+// WithClient uses the services of a preconfigured GitHub client
+func WithClient(client *github.Client) Option {
+	return func(a *App) {
+		a.artifacts = client.Actions
+		a.repositories = client.Repositories
+		a.workflowRuns = client.Actions
+		a.pullRequests = client.PullRequests
+		a.tags = client.Repositories
+		a.downloader = client.Actions
+	}
+}
+
+// WithArtifactService uses a custom implementation of the artifacts API
+func WithArtifactService(service ArtifactService) Option {
+	return func(a *App) {
+		a.artifacts = service
+	}
+}
+
+// WithRepositoryService uses a custom implementation of the repositories API
+func WithRepositoryService(service RepositoryService) Option {
+	return func(a *App) {
+		a.repositories = service
+	}
+}
+
+// WithContext sets the context of the clients created by NewWithOptions, such as for GitHub App token requests.
+// Runs use the context passed to Run, Plan or Apply.
+func WithContext(ctx context.Context) Option {
+	return func(a *App) {
+		a.context = &ctx
+	}
+}
+
+// WithLogger sets the logger, which defaults to the standard logrus logger
+func WithLogger(logger log.FieldLogger) Option {
+	return func(a *App) {
+		a.logger = logger
+	}
+}
+
+// WithRetryPolicy sets how failed API calls are retried, which defaults to DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(a *App) {
+		a.Retry = policy
+	}
+}
+
+// WithTimeouts sets the timeouts of a run and its API calls, which default to DefaultTimeouts
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(a *App) {
+		a.Timeouts = timeouts
+	}
+}
+
+// WithRepository targets a single repository
+func WithRepository(owner string, repo string) Option {
+	return func(a *App) {
+		a.Owner = &owner
+		a.Repo = &repo
+	}
+}
+
+// NewWithOptions creates an instance of App. Filters are configured via the exported fields of the result.
+// Without WithClient or WithArtifactService, a client for WithEndpoint is created, which authenticates via
+// WithGitHubApp or the GITHUB_TOKEN environment variable.
+func NewWithOptions(options ...Option) (*App, error) {
+	app := &App{Retry: DefaultRetryPolicy(), Timeouts: DefaultTimeouts(), runs: newRunCache()}
+	for _, option := range options {
+		option(app)
+	}
+
+	if app.context == nil {
+		ctx := context.Background()
+		app.context = &ctx
+	}
+
+	if app.artifacts == nil {
+		ctx := *app.context
+		if app.endpoint != nil {
+			if err := app.endpoint.validate(); err != nil {
+				return nil, err
+			}
+			transport, err := app.endpoint.transport()
+			if err != nil {
+				return nil, err
+			}
+			// oauth2 sends authenticated requests via the client of the context
+			ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
+		}
+
+		httpClient, err := app.authenticatedClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		client := app.newGitHubClient(httpClient)
+		app.artifacts = client.Actions
+		if app.repositories == nil {
+			app.repositories = client.Repositories
+		}
+		if app.pullRequests == nil {
+			app.pullRequests = client.PullRequests
+		}
+		if app.tags == nil {
+			app.tags = client.Repositories
+		}
+	}
+	if runs, ok := app.artifacts.(WorkflowRunService); ok && app.workflowRuns == nil {
+		app.workflowRuns = runs
+	}
+	if downloader, ok := app.artifacts.(ArtifactDownloader); ok && app.downloader == nil {
+		app.downloader = downloader
+	}
+	if app.downloads == nil {
+		// downloads are fetched from blob storage without the credentials of the API, but via the same proxy and TLS
+		app.downloads = &http.Client{}
+		if app.endpoint != nil {
+			transport, err := app.endpoint.transport()
+			if err != nil {
+				return nil, err
+			}
+			app.downloads.Transport = transport
+		}
+	}
+
+	return app, nil
+}
+
+// authenticatedClient returns an HTTP client which authenticates as the installation of a GitHub App when configured,
+// otherwise with the GITHUB_TOKEN environment variable
+func (a *App) authenticatedClient(ctx context.Context) (*http.Client, error) {
+	if a.githubApp != nil {
+		return a.githubApp.httpClient(ctx, a.newGitHubClient)
+	}
+
+	token, found := os.LookupEnv("GITHUB_TOKEN")
+	if !found {
+		return nil, errors.New("GITHUB_TOKEN environment variable is missing")
+	}
+	ts := oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: token},
+	)
+	return oauth2.NewClient(ctx, ts), nil
+}
+
+func (a *App) log() log.FieldLogger {
+	if a.logger == nil {
+		return log.StandardLogger()
+	}
+	return a.logger
+}
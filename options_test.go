@@ -0,0 +1,182 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// fakeArtifactService serves artifacts from memory, a page of perPage at a time
+type fakeArtifactService struct {
+	artifacts []*github.Artifact
+	perPage   int
+	deleted   []int64
+	deleteErr map[int64]error
+}
+
+func (f *fakeArtifactService) page(opts *github.ListOptions) *github.ArtifactList {
+	start := (opts.Page - 1) * f.perPage
+	end := start + f.perPage
+	if start > len(f.artifacts) {
+		start = len(f.artifacts)
+	}
+	if end > len(f.artifacts) {
+		end = len(f.artifacts)
+	}
+	total := int64(len(f.artifacts))
+	return &github.ArtifactList{TotalCount: &total, Artifacts: f.artifacts[start:end]}
+}
+
+func (f *fakeArtifactService) ListArtifacts(_ context.Context, _, _ string, opts *github.ListArtifactsOptions) (*github.ArtifactList, *github.Response, error) {
+	return f.page(&opts.ListOptions), &github.Response{}, nil
+}
+
+func (f *fakeArtifactService) ListWorkflowRunArtifacts(_ context.Context, _, _ string, _ int64, opts *github.ListOptions) (*github.ArtifactList, *github.Response, error) {
+	return f.page(opts), &github.Response{}, nil
+}
+
+func (f *fakeArtifactService) GetArtifact(_ context.Context, _, _ string, artifactID int64) (*github.Artifact, *github.Response, error) {
+	for _, artifact := range f.artifacts {
+		if artifact.GetID() == artifactID {
+			return artifact, &github.Response{}, nil
+		}
+	}
+	return nil, &github.Response{Response: &http.Response{StatusCode: http.StatusNotFound}}, errors.New("not found")
+}
+
+func (f *fakeArtifactService) DeleteArtifact(_ context.Context, _, _ string, artifactID int64) (*github.Response, error) {
+	if err, ok := f.deleteErr[artifactID]; ok {
+		return &github.Response{Response: &http.Response{StatusCode: http.StatusBadRequest}}, err
+	}
+	f.deleted = append(f.deleted, artifactID)
+	return &github.Response{}, nil
+}
+
+func quietLogger() log.FieldLogger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestNewWithOptions(t *testing.T) {
+	service := &fakeArtifactService{}
+	ctx := context.WithValue(context.Background(), struct{}{}, "value")
+	logger := quietLogger()
+
+	app, err := NewWithOptions(
+		WithRepository("octo-org", "octo-docs"),
+		WithArtifactService(service),
+		WithContext(ctx),
+		WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if *app.Owner != "octo-org" || *app.Repo != "octo-docs" {
+		t.Errorf("expected octo-org/octo-docs, got %s/%s", *app.Owner, *app.Repo)
+	}
+	if app.artifacts != service {
+		t.Errorf("expected the custom artifact service")
+	}
+	if *app.context != ctx {
+		t.Errorf("expected the custom context")
+	}
+	if app.log() != logger {
+		t.Errorf("expected the custom logger")
+	}
+}
+
+func TestNewWithOptions_WithClient(t *testing.T) {
+	client := github.NewClient(nil)
+	app, err := NewWithOptions(WithClient(client))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if app.artifacts != client.Actions || app.repositories != client.Repositories {
+		t.Errorf("expected the services of the custom client")
+	}
+}
+
+func TestNewWithOptions_MissingToken(t *testing.T) {
+	// t.Setenv restores the original value once the test completes
+	t.Setenv("GITHUB_TOKEN", "")
+	if err := os.Unsetenv("GITHUB_TOKEN"); err != nil {
+		t.Fatalf("unable to unset GITHUB_TOKEN: %v", err)
+	}
+	if _, err := NewWithOptions(); err == nil {
+		t.Errorf("expected an error without a client or GITHUB_TOKEN")
+	}
+}
+
+func TestRun_WithArtifactService(t *testing.T) {
+	now := time.Now()
+	service := &fakeArtifactService{
+		perPage: 2,
+		artifacts: []*github.Artifact{
+			createSizedArtifact(1, 100, now.Add(-4*time.Hour)),
+			createSizedArtifact(2, 10, now.Add(-3*time.Hour)),
+			createSizedArtifact(3, 200, now.Add(-2*time.Hour)),
+			createSizedArtifact(4, 300, now.Add(-1*time.Hour)),
+			createSizedArtifact(5, 400, now.Add(-1*time.Hour)),
+		},
+		deleteErr: map[int64]error{4: errors.New("boom")},
+	}
+
+	app, err := NewWithOptions(
+		WithRepository("octo-org", "octo-docs"),
+		WithArtifactService(service),
+		WithLogger(quietLogger()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	app.MinBytes = 50
+
+	report, err := app.runRepo(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.Matched != 4 || report.Deleted != 3 || report.Failed != 1 || report.BytesReclaimed != 700 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	deleted := map[int64]bool{}
+	for _, id := range service.deleted {
+		deleted[id] = true
+	}
+	if len(deleted) != 3 || !deleted[1] || !deleted[3] || !deleted[5] {
+		t.Errorf("unexpected deletions: %v", service.deleted)
+	}
+}
+
+func TestRun_DryRunWithArtifactService(t *testing.T) {
+	service := &fakeArtifactService{
+		perPage:   100,
+		artifacts: []*github.Artifact{createSizedArtifact(1, 100, time.Now())},
+	}
+
+	app, err := NewWithOptions(
+		WithRepository("octo-org", "octo-docs"),
+		WithArtifactService(service),
+		WithLogger(quietLogger()),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	app.DryRun = true
+
+	if _, err := app.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(service.deleted) != 0 {
+		t.Errorf("expected no deletions during a dry run, got %v", service.deleted)
+	}
+}
@@ -0,0 +1,206 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultRepoConcurrency = 4
+
+// runOrg applies the filters to every repository of Org, up to RepoConcurrency repositories at a time. Repositories
+// not yet started when ctx is cancelled are reported with the context's error.
+func (a *App) runOrg(ctx context.Context) (*Report, error) {
+	if a.repositories == nil {
+		return nil, errors.New("org requires a repository service, see WithRepositoryService")
+	}
+
+	listContext, cancel := within(ctx, a.Timeouts.Run)
+	defer cancel()
+
+	a.log().WithFields(log.Fields{"org": a.Org}).Info("delete-artifacts is listing the repositories of the organization")
+	repos, err := a.listOrgRepositories(listContext)
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err = a.filterRepositories(repos)
+	if err != nil {
+		return nil, err
+	}
+	a.log().WithFields(log.Fields{"org": a.Org, "count": len(repos)}).Info("Sweeping repositories.")
+
+	concurrency := a.RepoConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultRepoConcurrency
+	}
+
+	reports := make([]*Report, len(repos))
+	semaphore := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i, repo := range repos {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			report := newReport(a.Org, repo.GetName())
+			report.Err = ctx.Err()
+			reports[i] = report
+			continue
+		}
+		wg.Add(1)
+		go func(i int, owner string, name string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			repoApp := *a
+			repoApp.Owner = &owner
+			repoApp.Repo = &name
+			report, err := repoApp.runRepo(ctx)
+			if report == nil {
+				report = newReport(owner, name)
+			}
+			report.Err = err
+			reports[i] = report
+		}(i, a.Org, repo.GetName())
+	}
+	wg.Wait()
+
+	total := newReport(a.Org, "")
+	for _, report := range reports {
+		total.merge(report)
+	}
+	a.logOrgSummary(total)
+	if err := ctx.Err(); err != nil {
+		total.Err = err
+		return total, err
+	}
+	return total, nil
+}
+
+func (a *App) logOrgSummary(total *Report) {
+	for _, r := range total.Repositories {
+		fields := log.Fields{"repo": r.Owner + "/" + r.Repo, "matched": r.Matched, "deleted": r.Deleted, "failed": r.Failed, "bytes": r.BytesReclaimed}
+		if r.Err != nil {
+			a.log().WithFields(fields).WithError(r.Err).Error("Repository summary")
+		} else {
+			a.log().WithFields(fields).Info("Repository summary")
+		}
+	}
+
+	a.log().WithFields(log.Fields{"repos": len(total.Repositories), "matched": total.Matched, "deleted": total.Deleted, "failed": total.Failed, "bytes": total.BytesReclaimed}).
+		Info("Organization summary")
+}
+
+// listOrgRepositories lists the repositories of Org, falling back to those of a user of the same name
+func (a *App) listOrgRepositories(ctx context.Context) ([]*github.Repository, error) {
+	all := make([]*github.Repository, 0)
+	opts := &github.RepositoryListByOrgOptions{Type: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		repos, resp, err := a.repositories.ListByOrg(ctx, a.Org, opts)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound && len(all) == 0 {
+				a.log().WithFields(log.Fields{"owner": a.Org}).Debug("Organization not found, listing repositories of a user instead.")
+				return a.listUserRepositories(ctx)
+			}
+			return nil, err
+		}
+		all = append(all, repos...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (a *App) listUserRepositories(ctx context.Context) ([]*github.Repository, error) {
+	all := make([]*github.Repository, 0)
+	opts := &github.RepositoryListByUserOptions{Type: "owner", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		repos, resp, err := a.repositories.ListByUser(ctx, a.Org, opts)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, repos...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (a *App) filterRepositories(repos []*github.Repository) ([]*github.Repository, error) {
+	var re *regexp.Regexp
+	if len(a.RepoPattern) > 0 {
+		var err error
+		re, err = regexp.CompilePOSIX(a.RepoPattern)
+		if err != nil {
+			return nil, fmt.Errorf("repo pattern is invalid: %w", err)
+		}
+	}
+
+	filtered := make([]*github.Repository, 0)
+	for _, repo := range repos {
+		fields := log.Fields{"repo": repo.GetFullName()}
+		switch {
+		case repo.GetArchived() && !a.IncludeArchived:
+			a.log().WithFields(fields).Debug("Skipping archived repository.")
+		case repo.GetDisabled():
+			a.log().WithFields(fields).Debug("Skipping disabled repository.")
+		case re != nil && !re.MatchString(repo.GetName()):
+			a.log().WithFields(fields).Debug("Skipping repository not matching the repo pattern.")
+		case len(a.Topic) > 0 && !hasTopic(repo, a.Topic):
+			a.log().WithFields(fields).Debug("Skipping repository without the topic.")
+		case !a.matchesVisibility(repo):
+			a.log().WithFields(fields).Debug("Skipping repository with a different visibility.")
+		default:
+			filtered = append(filtered, repo)
+		}
+	}
+	return filtered, nil
+}
+
+func hasTopic(repo *github.Repository, topic string) bool {
+	for _, t := range repo.Topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *App) matchesVisibility(repo *github.Repository) bool {
+	if len(a.Visibility) == 0 || a.Visibility == "all" {
+		return true
+	}
+	visibility := repo.GetVisibility()
+	if len(visibility) == 0 {
+		// older API versions and some endpoints only report whether the repository is private
+		visibility = "public"
+		if repo.GetPrivate() {
+			visibility = "private"
+		}
+	}
+	return visibility == a.Visibility
+}
+
+func (a *App) checkOrgPreconditions() error {
+	if len(a.Org) <= 1 {
+		return errors.New("org is invalid")
+	}
+	switch a.Visibility {
+	case "", "all", "public", "private", "internal":
+	default:
+		return fmt.Errorf("visibility %q is invalid, expected all, public, private or internal", a.Visibility)
+	}
+	if a.RunId != nil {
+		return errors.New("run-id can't be combined with org")
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// Helper to create a test repository
+func createRepository(name string, visibility string, archived bool, topics ...string) *github.Repository {
+	return &github.Repository{
+		Name:       &name,
+		FullName:   github.Ptr("octo-org/" + name),
+		Visibility: &visibility,
+		Archived:   &archived,
+		Topics:     topics,
+	}
+}
+
+func TestFilterRepositories(t *testing.T) {
+	repos := []*github.Repository{
+		createRepository("api", "private", false, "backend"),
+		createRepository("web", "public", false, "frontend"),
+		createRepository("legacy-api", "private", true, "backend"),
+		createRepository("docs", "internal", false),
+	}
+
+	tests := []struct {
+		name string
+		app  *App
+		want []string
+	}{
+		{
+			name: "no filters - archived excluded",
+			app:  &App{},
+			want: []string{"api", "web", "docs"},
+		},
+		{
+			name: "include archived",
+			app:  &App{IncludeArchived: true},
+			want: []string{"api", "web", "legacy-api", "docs"},
+		},
+		{
+			name: "repo pattern",
+			app:  &App{RepoPattern: "api$", IncludeArchived: true},
+			want: []string{"api", "legacy-api"},
+		},
+		{
+			name: "topic",
+			app:  &App{Topic: "backend"},
+			want: []string{"api"},
+		},
+		{
+			name: "visibility",
+			app:  &App{Visibility: "internal"},
+			want: []string{"docs"},
+		},
+		{
+			name: "visibility all",
+			app:  &App{Visibility: "all"},
+			want: []string{"api", "web", "docs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.app.filterRepositories(repos)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(result) != len(tt.want) {
+				t.Fatalf("expected %d repositories, got %d", len(tt.want), len(result))
+			}
+			for i, name := range tt.want {
+				if result[i].GetName() != name {
+					t.Errorf("expected repository %s at position %d, got %s", name, i, result[i].GetName())
+				}
+			}
+		})
+	}
+}
+
+func TestFilterRepositories_InvalidPattern(t *testing.T) {
+	app := &App{RepoPattern: "[invalid"}
+	if _, err := app.filterRepositories(nil); err == nil {
+		t.Errorf("expected an error for an invalid repo pattern")
+	}
+}
+
+func TestMatchesVisibility_PrivateFallback(t *testing.T) {
+	repo := &github.Repository{Name: github.Ptr("api"), Private: github.Ptr(true)}
+
+	if !(&App{Visibility: "private"}).matchesVisibility(repo) {
+		t.Errorf("expected a private repository without visibility to match private")
+	}
+	if (&App{Visibility: "public"}).matchesVisibility(repo) {
+		t.Errorf("expected a private repository without visibility not to match public")
+	}
+}
+
+func TestCheckPreconditions_Org(t *testing.T) {
+	tests := []struct {
+		name    string
+		app     *App
+		wantErr bool
+	}{
+		{
+			name:    "org without owner or repo",
+			app:     &App{Org: "octo-org"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid visibility",
+			app:     &App{Org: "octo-org", Visibility: "secret"},
+			wantErr: true,
+		},
+		{
+			name:    "run id with org",
+			app:     &App{Org: "octo-org", RunId: int64Ptr(1)},
+			wantErr: true,
+		},
+		{
+			name:    "no org and no repo",
+			app:     &App{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.app.checkPreconditions()
+			if tt.wantErr && err == nil {
+				t.Errorf("expected an error, got none")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// FormatJSON writes the report as a single JSON document
+	FormatJSON = "json"
+	// FormatNDJSON writes one JSON object per artifact
+	FormatNDJSON = "ndjson"
+	// FormatCSV writes one CSV row per artifact, following a header
+	FormatCSV = "csv"
+	// FormatTable writes an aligned, human-readable table followed by totals
+	FormatTable = "table"
+)
+
+// OutcomeRecord is the machine-readable form of an Outcome
+type OutcomeRecord struct {
+	Owner         string     `json:"owner"`
+	Repo          string     `json:"repo"`
+	ID            int64      `json:"id"`
+	Name          string     `json:"name"`
+	SizeInBytes   int64      `json:"size_in_bytes"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	WorkflowRunID int64      `json:"workflow_run_id,omitempty"`
+	Status        Status     `json:"status"`
+	Reason        string     `json:"reason,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// ReportRecord is the machine-readable form of a Report
+type ReportRecord struct {
+	Matched        int              `json:"matched"`
+	Deleted        int              `json:"deleted"`
+	Failed         int              `json:"failed"`
+	Skipped        int              `json:"skipped"`
+	Downloaded     int              `json:"downloaded,omitempty"`
+	BytesReclaimed int64            `json:"bytes_reclaimed"`
+	Cancelled      bool             `json:"cancelled,omitempty"`
+	Artifacts      []*OutcomeRecord `json:"artifacts"`
+}
+
+// Record converts an outcome to its machine-readable form
+func (o *Outcome) Record() *OutcomeRecord {
+	artifact := o.Artifact
+	record := &OutcomeRecord{
+		Owner:         o.Owner,
+		Repo:          o.Repo,
+		ID:            artifact.GetID(),
+		Name:          artifact.GetName(),
+		SizeInBytes:   artifact.GetSizeInBytes(),
+		WorkflowRunID: artifact.GetWorkflowRun().GetID(),
+		Status:        o.Status,
+		Reason:        o.Reason,
+	}
+	if artifact.CreatedAt != nil {
+		record.CreatedAt = &artifact.CreatedAt.Time
+	}
+	if artifact.ExpiresAt != nil {
+		record.ExpiresAt = &artifact.ExpiresAt.Time
+	}
+	if o.Err != nil {
+		record.Error = o.Err.Error()
+	}
+	return record
+}
+
+// Record converts a report to its machine-readable form
+func (r *Report) Record() *ReportRecord {
+	record := &ReportRecord{
+		Matched:        r.Matched,
+		Deleted:        r.Deleted,
+		Failed:         r.Failed,
+		Skipped:        r.Skipped,
+		Downloaded:     r.Downloaded,
+		BytesReclaimed: r.BytesReclaimed,
+		Cancelled:      r.Result() == ResultCancelled,
+		Artifacts:      make([]*OutcomeRecord, 0, len(r.Outcomes)),
+	}
+	for _, outcome := range r.Outcomes {
+		record.Artifacts = append(record.Artifacts, outcome.Record())
+	}
+	return record
+}
+
+// WriteReport writes the report to w in one of the supported formats
+func WriteReport(w io.Writer, format string, report *Report) error {
+	record := report.Record()
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(record)
+	case FormatNDJSON:
+		encoder := json.NewEncoder(w)
+		for _, artifact := range record.Artifacts {
+			if err := encoder.Encode(artifact); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		return writeCSV(w, record)
+	case FormatTable:
+		return writeTable(w, record)
+	default:
+		return fmt.Errorf("output format %q is invalid, expected %s, %s, %s or %s", format, FormatJSON, FormatNDJSON, FormatCSV, FormatTable)
+	}
+}
+
+var recordColumns = []string{"owner", "repo", "id", "name", "size_in_bytes", "created_at", "expires_at", "workflow_run_id", "status", "reason", "error"}
+
+func (o *OutcomeRecord) columns() []string {
+	return []string{
+		o.Owner,
+		o.Repo,
+		strconv.FormatInt(o.ID, 10),
+		o.Name,
+		strconv.FormatInt(o.SizeInBytes, 10),
+		formatTime(o.CreatedAt),
+		formatTime(o.ExpiresAt),
+		strconv.FormatInt(o.WorkflowRunID, 10),
+		string(o.Status),
+		o.Reason,
+		o.Error,
+	}
+}
+
+func writeCSV(w io.Writer, record *ReportRecord) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(recordColumns); err != nil {
+		return err
+	}
+	for _, artifact := range record.Artifacts {
+		if err := writer.Write(artifact.columns()); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeTable(w io.Writer, record *ReportRecord) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "REPO\tID\tNAME\tSIZE\tCREATED\tRUN\tSTATUS\tDETAIL")
+	for _, a := range record.Artifacts {
+		detail := a.Reason
+		if len(a.Error) > 0 {
+			detail = a.Error
+		}
+		_, _ = fmt.Fprintf(writer, "%s/%s\t%d\t%s\t%d\t%s\t%d\t%s\t%s\n",
+			a.Owner, a.Repo, a.ID, a.Name, a.SizeInBytes, formatTime(a.CreatedAt), a.WorkflowRunID, a.Status, detail)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	if record.Downloaded > 0 {
+		_, err := fmt.Fprintf(w, "\nmatched: %d, downloaded: %d, failed: %d, skipped: %d\n",
+			record.Matched, record.Downloaded, record.Failed, record.Skipped)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\nmatched: %d, deleted: %d, failed: %d, skipped: %d, bytes reclaimed: %d\n",
+		record.Matched, record.Deleted, record.Failed, record.Skipped, record.BytesReclaimed)
+	return err
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
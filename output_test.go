@@ -0,0 +1,116 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+func createOutputReport() *Report {
+	createdAt := time.Date(2020, 1, 10, 14, 59, 22, 0, time.UTC)
+	deleted := createServedArtifact(11, "Rails", 556, 42)
+	deleted.CreatedAt = &github.Timestamp{Time: createdAt}
+	deleted.ExpiresAt = &github.Timestamp{Time: createdAt.Add(24 * time.Hour)}
+	failed := createServedArtifact(13, "coverage", 453, 42)
+	failed.CreatedAt = &github.Timestamp{Time: createdAt}
+
+	report := newReport("octo-org", "octo-docs")
+	report.Matched = 2
+	report.deleted(deleted)
+	report.failed(failed, errors.New("boom"))
+	return report
+}
+
+func TestWriteReport_JSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteReport(buf, FormatJSON, createOutputReport()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	record := &ReportRecord{}
+	if err := json.Unmarshal(buf.Bytes(), record); err != nil {
+		t.Fatalf("expected valid JSON: %v", err)
+	}
+	if record.Matched != 2 || record.Deleted != 1 || record.Failed != 1 || record.BytesReclaimed != 556 {
+		t.Errorf("unexpected totals: %+v", record)
+	}
+	if len(record.Artifacts) != 2 {
+		t.Fatalf("expected 2 artifacts, got %d", len(record.Artifacts))
+	}
+	first := record.Artifacts[0]
+	if first.ID != 11 || first.Name != "Rails" || first.WorkflowRunID != 42 || first.Status != StatusDeleted {
+		t.Errorf("unexpected artifact: %+v", first)
+	}
+	if first.CreatedAt == nil || first.ExpiresAt == nil || !first.ExpiresAt.Equal(first.CreatedAt.Add(24*time.Hour)) {
+		t.Errorf("expected timestamps, got %v and %v", first.CreatedAt, first.ExpiresAt)
+	}
+	if record.Artifacts[1].Error != "boom" {
+		t.Errorf("expected the error of the failed artifact, got %q", record.Artifacts[1].Error)
+	}
+}
+
+func TestWriteReport_NDJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteReport(buf, FormatNDJSON, createOutputReport()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		record := &OutcomeRecord{}
+		if err := json.Unmarshal([]byte(line), record); err != nil {
+			t.Errorf("expected each line to be a JSON object: %v", err)
+		}
+	}
+}
+
+func TestWriteReport_CSV(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteReport(buf, FormatCSV, createOutputReport()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatalf("expected valid CSV: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected a header and 2 rows, got %d", len(rows))
+	}
+	if strings.Join(rows[0], ",") != strings.Join(recordColumns, ",") {
+		t.Errorf("unexpected header: %v", rows[0])
+	}
+	expected := []string{"octo-org", "octo-docs", "11", "Rails", "556", "2020-01-10T14:59:22Z", "2020-01-11T14:59:22Z", "42", "deleted", "", ""}
+	if strings.Join(rows[1], ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected row: %v", rows[1])
+	}
+}
+
+func TestWriteReport_Table(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteReport(buf, FormatTable, createOutputReport()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"NAME", "octo-org/octo-docs", "Rails", "boom", "matched: 2, deleted: 1, failed: 1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected table to contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteReport_InvalidFormat(t *testing.T) {
+	if err := WriteReport(&bytes.Buffer{}, "xml", createOutputReport()); err == nil {
+		t.Errorf("expected an error for an unsupported format")
+	}
+}
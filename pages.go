@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// artifactsPerPage is the largest page size the API allows
+	artifactsPerPage = 100
+	// defaultPageConcurrency is the number of pages listed concurrently when PageConcurrency isn't set
+	defaultPageConcurrency = 4
+)
+
+// listPage lists a page of the artifacts of the repository, or of the workflow run RunId
+func (a *App) listPage(ctx context.Context, gate *rateGate, page int) (*github.ArtifactList, error) {
+	ctx, cancel := within(ctx, a.Timeouts.Request)
+	defer cancel()
+
+	var list *github.ArtifactList
+	opts := &github.ListOptions{PerPage: artifactsPerPage, Page: page}
+	_, err := a.withRetry(ctx, gate, func(ctx context.Context) (resp *github.Response, err error) {
+		if a.RunId != nil {
+			a.log().WithFields(log.Fields{"runId": *a.RunId, "page": page}).Debug("Querying artifacts for a specific run.")
+			list, resp, err = a.artifacts.ListWorkflowRunArtifacts(ctx, *a.Owner, *a.Repo, *a.RunId, opts)
+		} else {
+			a.log().WithFields(log.Fields{"page": page}).Debug("Querying artifacts across all workflows.")
+			list, resp, err = a.artifacts.ListArtifacts(ctx, *a.Owner, *a.Repo, &github.ListArtifactsOptions{ListOptions: *opts})
+		}
+		return resp, err
+	})
+	return list, err
+}
+
+// listArtifacts lists every page of artifacts, calling yield with each page in order until it returns false
+func (a *App) listArtifacts(ctx context.Context, gate *rateGate, yield func([]*github.Artifact) bool) error {
+	first, err := a.listPage(ctx, gate, 1)
+	if err != nil {
+		return err
+	}
+	if !yield(first.Artifacts) {
+		return nil
+	}
+	return a.listAfter(ctx, gate, first, yield)
+}
+
+// listAfter lists the pages following first, calling yield with each page in order until it returns false. The
+// total count of first tells how many pages follow, which are listed PageConcurrency at a time.
+func (a *App) listAfter(ctx context.Context, gate *rateGate, first *github.ArtifactList, yield func([]*github.Artifact) bool) error {
+	perPage := len(first.Artifacts)
+	if perPage == 0 {
+		return nil
+	}
+
+	if first.TotalCount == nil {
+		// without a total count, pages are listed one at a time until one is shorter than the first
+		for page := 2; ; page++ {
+			list, err := a.listPage(ctx, gate, page)
+			if err != nil {
+				return err
+			}
+			if len(list.Artifacts) == 0 || !yield(list.Artifacts) || len(list.Artifacts) < perPage {
+				return nil
+			}
+		}
+	}
+
+	last := lastPage(first)
+	pages := make([]int, 0, last)
+	for page := 2; page <= last; page++ {
+		pages = append(pages, page)
+	}
+	_, err := a.fetchPages(ctx, gate, pages, yield)
+	return err
+}
+
+// lastPage is the number of pages holding the total count of artifacts, given the size of the first page. The API may
+// cap the page size below artifactsPerPage, so it is taken from the first page.
+func lastPage(first *github.ArtifactList) int {
+	perPage := len(first.Artifacts)
+	if first.TotalCount == nil || perPage == 0 {
+		return 1
+	}
+	last := (int(first.GetTotalCount()) + perPage - 1) / perPage
+	if last < 1 {
+		return 1
+	}
+	return last
+}
+
+// fetchPages lists pages with up to PageConcurrency concurrent requests, and calls yield with each page in the order
+// given until it returns false. At most PageConcurrency pages are listed ahead of yield, which bounds memory when yield
+// is slow. It returns true when every page was yielded.
+func (a *App) fetchPages(ctx context.Context, gate *rateGate, pages []int, yield func([]*github.Artifact) bool) (bool, error) {
+	if len(pages) == 0 {
+		return true, nil
+	}
+
+	// requests still in flight once listing stops are cancelled, and awaited before returning
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := a.PageConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultPageConcurrency
+	}
+	a.log().WithFields(log.Fields{"pages": len(pages), "concurrency": concurrency}).Debug("Listing pages concurrently.")
+
+	type result struct {
+		list *github.ArtifactList
+		err  error
+	}
+	results := make([]chan result, len(pages))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+
+	slots := make(chan struct{}, concurrency)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i, page := range pages {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				list, err := a.listPage(ctx, gate, page)
+				results[i] <- result{list: list, err: err}
+			}()
+		}
+	}()
+
+	for i := range pages {
+		var r result
+		select {
+		case r = <-results[i]:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+		<-slots
+		if r.err != nil {
+			return false, r.err
+		}
+		if !yield(r.list.Artifacts) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// concurrentService answers later pages sooner, and records the most pages listed at once
+type concurrentService struct {
+	*fakeArtifactService
+	failPage int
+	noTotal  bool
+
+	mu       sync.Mutex
+	inFlight int
+	most     int
+	listed   int
+}
+
+func (c *concurrentService) ListArtifacts(ctx context.Context, owner, repo string, opts *github.ListArtifactsOptions) (*github.ArtifactList, *github.Response, error) {
+	c.mu.Lock()
+	c.inFlight++
+	c.listed++
+	if c.inFlight > c.most {
+		c.most = c.inFlight
+	}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}()
+
+	time.Sleep(time.Duration(20-opts.Page) * time.Millisecond)
+	if opts.Page == c.failPage {
+		return nil, &github.Response{}, errors.New("boom")
+	}
+	list, resp, err := c.fakeArtifactService.ListArtifacts(ctx, owner, repo, opts)
+	if c.noTotal {
+		list.TotalCount = nil
+	}
+	return list, resp, err
+}
+
+func newConcurrentService(count int64) *concurrentService {
+	artifacts := make([]*github.Artifact, 0)
+	for i := int64(1); i <= count; i++ {
+		artifacts = append(artifacts, createServedArtifact(i, "a", 100, 1))
+	}
+	return &concurrentService{fakeArtifactService: &fakeArtifactService{artifacts: artifacts, perPage: 10}}
+}
+
+func TestListArtifacts(t *testing.T) {
+	tests := []struct {
+		name        string
+		count       int64
+		noTotal     bool
+		concurrency int
+		wantListed  int
+		wantMost    int
+	}{
+		{"single page", 7, false, 3, 1, 1},
+		{"fan out", 95, false, 3, 10, 3},
+		{"default concurrency", 95, false, 0, 10, defaultPageConcurrency},
+		{"exact pages", 30, false, 3, 3, 2},
+		// without a total count, pages are listed one at a time until a page isn't full
+		{"no total count", 30, true, 3, 4, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newConcurrentService(tt.count)
+			service.noTotal = tt.noTotal
+			app, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+			app.PageConcurrency = tt.concurrency
+
+			listed := make([]int64, 0)
+			err := app.listArtifacts(context.Background(), &rateGate{}, func(items []*github.Artifact) bool {
+				for _, artifact := range items {
+					listed = append(listed, artifact.GetID())
+				}
+				return true
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if int64(len(listed)) != tt.count {
+				t.Fatalf("expected %d artifacts, got %d", tt.count, len(listed))
+			}
+			for i, id := range listed {
+				if id != int64(i+1) {
+					t.Fatalf("expected artifacts in page order, got %v", listed)
+				}
+			}
+			if service.listed != tt.wantListed || service.most != tt.wantMost {
+				t.Errorf("expected %d pages listed, at most %d at once, got %d and %d", tt.wantListed, tt.wantMost, service.listed, service.most)
+			}
+		})
+	}
+}
+
+func TestListArtifacts_Error(t *testing.T) {
+	service := newConcurrentService(95)
+	service.failPage = 5
+	app, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+	pages := 0
+	err := app.listArtifacts(context.Background(), &rateGate{}, func([]*github.Artifact) bool {
+		pages++
+		return true
+	})
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("expected the failed page to fail the listing, got %v", err)
+	}
+	if pages != 4 {
+		t.Errorf("expected the pages before the failure to be yielded, got %d", pages)
+	}
+}
+
+func TestListArtifacts_Stop(t *testing.T) {
+	service := newConcurrentService(95)
+	app, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+	app.PageConcurrency = 2
+
+	pages := 0
+	err := app.listArtifacts(context.Background(), &rateGate{}, func([]*github.Artifact) bool {
+		pages++
+		return pages < 3
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pages != 3 || service.listed > 5 {
+		t.Errorf("expected listing to stop after 3 pages, yielded %d and listed %d", pages, service.listed)
+	}
+}
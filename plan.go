@@ -0,0 +1,371 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// PlanVersion is the version of the plan format written by Plan
+const PlanVersion = 1
+
+// Plan is the exact set of artifacts a run would delete. It is written by App.Plan for review, and deleted by
+// App.Apply. Hash covers every other field, so a plan which was modified after review is rejected.
+type Plan struct {
+	Version   int                `json:"version"`
+	CreatedAt time.Time          `json:"created_at"`
+	Owner     string             `json:"owner"`
+	Repo      string             `json:"repo"`
+	Criteria  PlanCriteria       `json:"criteria"`
+	Artifacts []*PlannedArtifact `json:"artifacts"`
+	// Hash is the hex encoded SHA-256 of the plan, excluding Hash and Signature
+	Hash string `json:"hash"`
+	// Signature is the hex encoded HMAC-SHA256 of Hash, present when the plan was written with a key
+	Signature string `json:"signature,omitempty"`
+}
+
+// PlanCriteria are the filters in effect when a plan was written. Apply checks every artifact against the
+// per-artifact filters again; keep-last and budget depend on the full listing, and are recorded for review only.
+type PlanCriteria struct {
+	RunId            *int64  `json:"run_id,omitempty"`
+	MinBytes         int64   `json:"min_bytes"`
+	MaxBytes         *int64  `json:"max_bytes,omitempty"`
+	Name             string  `json:"name,omitempty"`
+	Pattern          string  `json:"pattern,omitempty"`
+	ActiveDuration   string  `json:"active,omitempty"`
+	Policy           *Policy `json:"policy,omitempty"`
+	KeepLast         int     `json:"keep_last,omitempty"`
+	KeepLastByBranch bool    `json:"keep_last_by_branch,omitempty"`
+	Budget           *int64  `json:"budget,omitempty"`
+	BudgetOrder      string  `json:"budget_order,omitempty"`
+	Workflow         string  `json:"workflow,omitempty"`
+	Branch           string  `json:"branch,omitempty"`
+	HeadSHA          string  `json:"head_sha,omitempty"`
+	Event            string  `json:"event,omitempty"`
+	Conclusion       string  `json:"conclusion,omitempty"`
+	ClosedPRs        bool    `json:"closed_prs,omitempty"`
+	// ClosedPRGrace is in nanoseconds, as encoding/json writes a time.Duration
+	ClosedPRGrace time.Duration `json:"closed_pr_grace,omitempty"`
+	Protect       []string      `json:"protect,omitempty"`
+}
+
+// PlannedArtifact is an artifact slated for deletion by a plan
+type PlannedArtifact struct {
+	ID            int64      `json:"id"`
+	Name          string     `json:"name"`
+	SizeInBytes   int64      `json:"size_in_bytes"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	WorkflowRunID int64      `json:"workflow_run_id,omitempty"`
+}
+
+// Plan lists the artifacts of the repository and returns those the filters slate for deletion, without deleting
+// anything. The plan is signed with PlanKey when set.
+func (a *App) Plan(ctx context.Context) (*Plan, error) {
+	if len(a.Org) > 0 {
+		return nil, errors.New("plan supports a single repository, not an org")
+	}
+	if err := a.checkPreconditions(); err != nil {
+		return nil, err
+	}
+
+	a.log().WithFields(log.Fields{"owner": *a.Owner, "repo": *a.Repo}).Info("delete-artifacts is planning the repo")
+	executionContext, cancel := within(ctx, a.Timeouts.Run)
+	defer cancel()
+
+	selected, err := a.selectArtifacts(executionContext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Version:   PlanVersion,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Owner:     *a.Owner,
+		Repo:      *a.Repo,
+		Criteria:  a.criteria(),
+		Artifacts: make([]*PlannedArtifact, 0, len(selected)),
+	}
+	for _, artifact := range selected {
+		plan.Artifacts = append(plan.Artifacts, planned(artifact))
+	}
+	if err := plan.seal(a.PlanKey); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Apply deletes the artifacts of a plan. Each artifact is fetched again first, and skipped if it no longer exists,
+// has changed, or no longer matches the criteria of the plan. The plan must be signed with PlanKey when set.
+// Cancelling ctx returns the partial report along with the context's error, as Run does.
+func (a *App) Apply(ctx context.Context, plan *Plan) (*Report, error) {
+	if err := plan.Verify(a.PlanKey); err != nil {
+		return nil, err
+	}
+	if a.Owner != nil && a.Repo != nil && (*a.Owner != plan.Owner || *a.Repo != plan.Repo) {
+		return nil, fmt.Errorf("plan is for %s/%s, not %s/%s", plan.Owner, plan.Repo, *a.Owner, *a.Repo)
+	}
+	a.Owner = &plan.Owner
+	a.Repo = &plan.Repo
+
+	report := newReport(plan.Owner, plan.Repo)
+	report.Matched = len(plan.Artifacts)
+	a.log().WithFields(log.Fields{"owner": plan.Owner, "repo": plan.Repo, "count": len(plan.Artifacts)}).Info("delete-artifacts is applying a plan")
+
+	executionContext, cancel := within(ctx, a.Timeouts.Run)
+	defer func() { cancel() }()
+
+	filters := plan.Criteria.filters(a)
+	if err := filters.checkServices(); err != nil {
+		return nil, err
+	}
+	if _, err := filters.protections(); err != nil {
+		return nil, err
+	}
+	if err := a.checkLimits(); err != nil {
+		return nil, err
+	}
+	gate := &rateGate{}
+	verified := make([]*github.Artifact, 0, len(plan.Artifacts))
+	for _, p := range plan.Artifacts {
+		if ctx.Err() != nil {
+			report.skipped(p.artifact(), reasonCancelled)
+			continue
+		}
+		artifact, reason, err := a.verifyPlanned(executionContext, gate, filters, p)
+		switch {
+		case err != nil:
+			report.failed(p.artifact(), err)
+		case len(reason) > 0:
+			a.log().WithFields(log.Fields{"id": p.ID, "name": p.Name, "reason": reason}).Warn("Skipping planned artifact.")
+			report.skipped(p.artifact(), reason)
+		default:
+			verified = append(verified, artifact)
+		}
+	}
+
+	verified, err := a.limitDeletions(verified, report)
+	if err != nil {
+		report.Err = err
+		return report, err
+	}
+	if a.selector != nil {
+		verified, err = a.confirmDeletions(ctx, verified, report)
+		if err != nil {
+			report.Err = err
+			return report, err
+		}
+		// time spent reviewing the artifacts doesn't count toward the run timeout
+		cancel()
+		executionContext, cancel = within(ctx, a.Timeouts.Run)
+	}
+	a.deleteSelected(executionContext, verified, report)
+	if err := ctx.Err(); err != nil {
+		report.Err = err
+		return report, err
+	}
+	return report, nil
+}
+
+// verifyPlanned fetches a planned artifact, returning the reason to skip it if it can no longer be deleted as planned
+func (a *App) verifyPlanned(ctx context.Context, gate *rateGate, filters *App, p *PlannedArtifact) (*github.Artifact, string, error) {
+	ctx, cancel := within(ctx, a.Timeouts.Request)
+	defer cancel()
+
+	var artifact *github.Artifact
+	resp, err := a.withRetry(ctx, gate, func(ctx context.Context) (resp *github.Response, err error) {
+		artifact, resp, err = a.artifacts.GetArtifact(ctx, *a.Owner, *a.Repo, p.ID)
+		return resp, err
+	})
+	if err != nil && statusCode(resp) == http.StatusNotFound {
+		return nil, "no longer exists", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if artifact.GetName() != p.Name || artifact.GetSizeInBytes() != p.SizeInBytes || artifact.GetWorkflowRun().GetID() != p.WorkflowRunID {
+		return nil, "changed since the plan was written", nil
+	}
+	if artifact.GetExpired() {
+		return nil, "expired", nil
+	}
+	filters.resolveRuns(ctx, []*github.Artifact{artifact})
+	matched := filters.matchArtifacts([]*github.Artifact{artifact})
+	if len(matched) == 0 {
+		return nil, "no longer matches the plan criteria", nil
+	}
+	if _, protected := filters.protect(matched); len(protected) > 0 {
+		return nil, protected[0].reason(), nil
+	}
+	return artifact, "", nil
+}
+
+// Verify checks the hash of the plan, and its signature when key is set. Plans which were signed can only be
+// verified with a key, so that dropping the signature doesn't bypass it.
+func (p *Plan) Verify(key []byte) error {
+	if p.Version != PlanVersion {
+		return fmt.Errorf("plan version %d is unsupported, expected %d", p.Version, PlanVersion)
+	}
+
+	hash, err := p.contentHash()
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(hash), []byte(p.Hash)) {
+		return errors.New("plan hash does not match its contents; the plan was modified after it was written")
+	}
+
+	switch {
+	case len(key) == 0 && len(p.Signature) > 0:
+		return errors.New("plan is signed, but no key was provided to verify it")
+	case len(key) > 0 && len(p.Signature) == 0:
+		return errors.New("plan is not signed, but a key was provided")
+	case len(key) > 0 && !hmac.Equal([]byte(sign(key, p.Hash)), []byte(p.Signature)):
+		return errors.New("plan signature is invalid")
+	}
+	return nil
+}
+
+// WritePlan writes the plan to w as indented JSON
+func WritePlan(w io.Writer, plan *Plan) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(plan)
+}
+
+// ReadPlan reads a plan written by WritePlan. The plan must still be verified, which Apply does.
+func ReadPlan(r io.Reader) (*Plan, error) {
+	plan := &Plan{}
+	if err := json.NewDecoder(r).Decode(plan); err != nil {
+		return nil, fmt.Errorf("unable to parse plan: %w", err)
+	}
+	if plan.Criteria.Policy != nil {
+		if err := plan.Criteria.Policy.compile(); err != nil {
+			return nil, fmt.Errorf("invalid policy in plan: %w", err)
+		}
+	}
+	return plan, nil
+}
+
+func (p *Plan) seal(key []byte) error {
+	hash, err := p.contentHash()
+	if err != nil {
+		return err
+	}
+	p.Hash = hash
+	p.Signature = ""
+	if len(key) > 0 {
+		p.Signature = sign(key, hash)
+	}
+	return nil
+}
+
+func (p *Plan) contentHash() (string, error) {
+	content := *p
+	content.Hash = ""
+	content.Signature = ""
+	b, err := json.Marshal(&content)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func sign(key []byte, hash string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (a *App) criteria() PlanCriteria {
+	return PlanCriteria{
+		RunId:            a.RunId,
+		MinBytes:         a.MinBytes,
+		MaxBytes:         a.MaxBytes,
+		Name:             a.Name,
+		Pattern:          a.Pattern,
+		ActiveDuration:   a.ActiveDuration,
+		Policy:           a.Policy,
+		KeepLast:         a.KeepLast,
+		KeepLastByBranch: a.KeepLastByBranch,
+		Budget:           a.Budget,
+		BudgetOrder:      a.BudgetOrder,
+		Workflow:         a.Workflow,
+		Branch:           a.Branch,
+		HeadSHA:          a.HeadSHA,
+		Event:            a.Event,
+		Conclusion:       a.Conclusion,
+		ClosedPRs:        a.ClosedPRs,
+		ClosedPRGrace:    a.ClosedPRGrace,
+		Protect:          a.Protect,
+	}
+}
+
+// filters returns an App which applies the per-artifact filters of the criteria, looking up workflow runs via a.
+// Artifacts are protected by the rules of both the criteria and a.
+func (c PlanCriteria) filters(a *App) *App {
+	return &App{
+		Owner:          a.Owner,
+		Repo:           a.Repo,
+		MinBytes:       c.MinBytes,
+		MaxBytes:       c.MaxBytes,
+		Name:           c.Name,
+		Pattern:        c.Pattern,
+		ActiveDuration: c.ActiveDuration,
+		Policy:         c.Policy,
+		Workflow:       c.Workflow,
+		Branch:         c.Branch,
+		HeadSHA:        c.HeadSHA,
+		Event:          c.Event,
+		Conclusion:     c.Conclusion,
+		ClosedPRs:      c.ClosedPRs,
+		ClosedPRGrace:  c.ClosedPRGrace,
+		Protect:        append(append([]string(nil), c.Protect...), a.Protect...),
+		Retry:          a.Retry,
+		Timeouts:       a.Timeouts,
+		logger:         a.log(),
+		workflowRuns:   a.workflowRuns,
+		tags:           a.tags,
+		pullRequests:   a.pullRequests,
+		runs:           a.runs,
+	}
+}
+
+func planned(artifact *github.Artifact) *PlannedArtifact {
+	p := &PlannedArtifact{
+		ID:            artifact.GetID(),
+		Name:          artifact.GetName(),
+		SizeInBytes:   artifact.GetSizeInBytes(),
+		WorkflowRunID: artifact.GetWorkflowRun().GetID(),
+	}
+	if artifact.CreatedAt != nil {
+		createdAt := artifact.CreatedAt.UTC()
+		p.CreatedAt = &createdAt
+	}
+	return p
+}
+
+// artifact stands in for a planned artifact which couldn't be fetched, so that it can be reported
+func (p *PlannedArtifact) artifact() *github.Artifact {
+	artifact := &github.Artifact{
+		ID:          github.Ptr(p.ID),
+		Name:        github.Ptr(p.Name),
+		SizeInBytes: github.Ptr(p.SizeInBytes),
+		WorkflowRun: &github.ArtifactWorkflowRun{ID: github.Ptr(p.WorkflowRunID)},
+	}
+	if p.CreatedAt != nil {
+		artifact.CreatedAt = &github.Timestamp{Time: *p.CreatedAt}
+	}
+	return artifact
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+func TestPlanApply_FakeServer(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs",
+		createServedArtifact(1, "coverage", 1000, 10),
+		createServedArtifact(2, "coverage", 10, 10),
+		createServedArtifact(3, "bundle", 1000, 10),
+	)
+
+	planner := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+	planner.MinBytes = 100
+	plan, err := planner.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Artifacts) != 2 || plan.Artifacts[0].ID != 1 || plan.Artifacts[1].ID != 3 {
+		t.Fatalf("expected artifacts 1 and 3 to be planned, got %v", plan.Artifacts)
+	}
+	if deleted := server.Deleted("octo-org", "octo-docs"); len(deleted) != 0 {
+		t.Fatalf("expected planning to delete nothing, got %v", deleted)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePlan(&buf, plan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	read, err := ReadPlan(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// between plan and apply, artifact 3 is removed and a new matching artifact appears
+	if _, err := server.GitHubClient().Actions.DeleteArtifact(context.Background(), "octo-org", "octo-docs", 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server.AddArtifacts("octo-org", "octo-docs", createServedArtifact(4, "coverage", 1000, 11))
+
+	report, err := newServedApp(t, server).Apply(context.Background(), read)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Matched != 2 || report.Deleted != 1 || report.Skipped != 1 {
+		t.Errorf("expected 1 deleted and 1 skipped of 2 planned, got %+v", report)
+	}
+	deleted := server.Deleted("octo-org", "octo-docs")
+	if len(deleted) != 2 || deleted[0] != 3 || deleted[1] != 1 {
+		t.Errorf("expected only planned artifact 1 to be deleted by apply, got %v", deleted)
+	}
+	for _, outcome := range report.Outcomes {
+		if outcome.Status == StatusSkipped && (outcome.Artifact.GetID() != 3 || outcome.Reason != "no longer exists") {
+			t.Errorf("expected artifact 3 to be skipped as missing, got %d: %s", outcome.Artifact.GetID(), outcome.Reason)
+		}
+	}
+}
+
+func TestApply_Reverify(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(artifact *github.Artifact)
+		reason string
+	}{
+		{"unchanged", func(artifact *github.Artifact) {}, ""},
+		{"resized", func(artifact *github.Artifact) { artifact.SizeInBytes = github.Ptr(int64(5000)) }, "changed since the plan was written"},
+		{"expired", func(artifact *github.Artifact) { artifact.Expired = github.Ptr(true) }, "expired"},
+		{"no longer matches", func(artifact *github.Artifact) {
+			artifact.CreatedAt = &github.Timestamp{Time: artifact.CreatedAt.Add(time.Hour)}
+		}, "no longer matches the plan criteria"},
+		{"other branch", func(artifact *github.Artifact) {
+			artifact.WorkflowRun = &github.ArtifactWorkflowRun{ID: github.Ptr(int64(10)), HeadBranch: github.Ptr("feature/login")}
+		}, "no longer matches the plan criteria"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			artifact := createServedArtifact(1, "coverage", 1000, 10)
+			artifact.WorkflowRun.HeadBranch = github.Ptr("main")
+			service := &fakeArtifactService{artifacts: []*github.Artifact{artifact}, perPage: 10}
+			planner, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+			planner.ActiveDuration = "30m"
+			planner.Branch = "main"
+			plan, err := planner.Plan(context.Background())
+			if err != nil || len(plan.Artifacts) != 1 {
+				t.Fatalf("expected one planned artifact, got %v (%v)", plan, err)
+			}
+
+			changed := *artifact
+			tt.change(&changed)
+			service.artifacts = []*github.Artifact{&changed}
+
+			applier, _ := NewWithOptions(WithArtifactService(service), WithLogger(quietLogger()))
+			report, err := applier.Apply(context.Background(), plan)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(tt.reason) == 0 {
+				if len(service.deleted) != 1 || report.Deleted != 1 {
+					t.Errorf("expected the artifact to be deleted, got %+v", report)
+				}
+				return
+			}
+			if len(service.deleted) != 0 || report.Skipped != 1 || report.Outcomes[0].Reason != tt.reason {
+				t.Errorf("expected the artifact to be skipped because %q, got %+v", tt.reason, report.Outcomes[0])
+			}
+		})
+	}
+}
+
+func TestPlan_Verify(t *testing.T) {
+	newPlan := func(key string) *Plan {
+		plan := &Plan{
+			Version:   PlanVersion,
+			Owner:     "octo-org",
+			Repo:      "octo-docs",
+			Artifacts: []*PlannedArtifact{{ID: 1, Name: "coverage", SizeInBytes: 1000}},
+		}
+		if err := plan.seal([]byte(key)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return plan
+	}
+
+	tests := []struct {
+		name    string
+		plan    func() *Plan
+		key     string
+		wantErr string
+	}{
+		{"unsigned", func() *Plan { return newPlan("") }, "", ""},
+		{"signed", func() *Plan { return newPlan("secret") }, "secret", ""},
+		{"tampered", func() *Plan {
+			plan := newPlan("")
+			plan.Artifacts[0].ID = 2
+			return plan
+		}, "", "hash does not match"},
+		{"wrong key", func() *Plan { return newPlan("secret") }, "other", "signature is invalid"},
+		{"signature without key", func() *Plan { return newPlan("secret") }, "", "no key was provided"},
+		{"signature removed", func() *Plan {
+			plan := newPlan("secret")
+			plan.Signature = ""
+			return plan
+		}, "secret", "not signed"},
+		{"unknown version", func() *Plan {
+			plan := newPlan("")
+			plan.Version = 99
+			return plan
+		}, "", "unsupported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.plan().Verify([]byte(tt.key))
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPlan_PolicyRoundTrip(t *testing.T) {
+	policy, err := LoadPolicy("testdata/policy_example.yml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	service := &fakeArtifactService{artifacts: []*github.Artifact{createServedArtifact(1, "coverage", 1000, 10)}, perPage: 10}
+	planner, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+	planner.Policy = policy
+	planner.PlanKey = []byte("secret")
+	plan, err := planner.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePlan(&buf, plan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	read, err := ReadPlan(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := read.Verify([]byte("secret")); err != nil {
+		t.Errorf("expected a plan to verify after a round trip, got %v", err)
+	}
+	if read.Criteria.Policy == nil || len(read.Criteria.Policy.Rules) != len(policy.Rules) {
+		t.Errorf("expected the policy to be recorded in the plan, got %v", read.Criteria.Policy)
+	}
+}
+
+func TestPlan_Org(t *testing.T) {
+	app := &App{Org: "octo-org"}
+	if _, err := app.Plan(context.Background()); err == nil {
+		t.Error("expected an error planning an org")
+	}
+}
+
+func TestApply_OtherRepository(t *testing.T) {
+	plan := &Plan{Version: PlanVersion, Owner: "octo-org", Repo: "octo-docs"}
+	_ = plan.seal(nil)
+	app := &App{Owner: github.Ptr("octo-org"), Repo: github.Ptr("other"), artifacts: &fakeArtifactService{}}
+	if _, err := app.Apply(context.Background(), plan); err == nil || !strings.Contains(err.Error(), "not octo-org/other") {
+		t.Errorf("expected an error applying a plan to another repository, got %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Action is the outcome of a policy Rule for the artifacts it matches
+type Action string
+
+const (
+	// ActionDelete slates matching artifacts for deletion
+	ActionDelete Action = "delete"
+	// ActionKeep retains matching artifacts
+	ActionKeep Action = "keep"
+)
+
+// Policy is an ordered list of retention rules. Rules are evaluated first-match-wins against every artifact,
+// and artifacts not matched by any rule are kept.
+type Policy struct {
+	Rules []*Rule `yaml:"rules" json:"rules"`
+}
+
+// Rule is a named set of matchers along with the action applied to artifacts matching all of them
+type Rule struct {
+	Name   string `yaml:"name" json:"name,omitempty"`
+	Action Action `yaml:"action" json:"action"`
+	Match  Match  `yaml:"match" json:"match"`
+}
+
+// Match holds the conditions of a Rule. Empty conditions always match, so a delete rule must set at least one.
+type Match struct {
+	Name           string `yaml:"name" json:"name,omitempty"`
+	Pattern        string `yaml:"pattern" json:"pattern,omitempty"`
+	MinBytes       *int64 `yaml:"min" json:"min,omitempty"`
+	MaxBytes       *int64 `yaml:"max" json:"max,omitempty"`
+	ActiveDuration string `yaml:"active" json:"active,omitempty"`
+	Workflow       string `yaml:"workflow" json:"workflow,omitempty"`
+	Branch         string `yaml:"branch" json:"branch,omitempty"`
+	HeadSHA        string `yaml:"head_sha" json:"head_sha,omitempty"`
+	Event          string `yaml:"event" json:"event,omitempty"`
+	Conclusion     string `yaml:"conclusion" json:"conclusion,omitempty"`
+
+	re     *regexp.Regexp
+	active time.Duration
+}
+
+// empty is true when the match has no conditions
+func (m *Match) empty() bool {
+	return len(m.Name) == 0 && len(m.Pattern) == 0 && m.MinBytes == nil && m.MaxBytes == nil && len(m.ActiveDuration) == 0 &&
+		len(m.Workflow) == 0 && len(m.Branch) == 0 && len(m.HeadSHA) == 0 && len(m.Event) == 0 && len(m.Conclusion) == 0
+}
+
+// LoadPolicy reads and validates a YAML policy file
+func LoadPolicy(path string) (*Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// unknown keys are rejected, since a misspelled condition would otherwise leave a rule matching everything
+	policy := &Policy{}
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(policy); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unable to parse policy %s: %w", path, err)
+	}
+
+	if err := policy.compile(); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+
+	return policy, nil
+}
+
+func (p *Policy) compile() error {
+	if len(p.Rules) == 0 {
+		return errors.New("policy must define at least one rule")
+	}
+
+	seen := make(map[string]bool, len(p.Rules))
+	for i, rule := range p.Rules {
+		if rule == nil {
+			return fmt.Errorf("rule %d is empty", i+1)
+		}
+		if len(rule.Name) == 0 {
+			return fmt.Errorf("rule %d is missing a name", i+1)
+		}
+		if seen[rule.Name] {
+			return fmt.Errorf("rule %q is defined more than once", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		if rule.Action != ActionDelete && rule.Action != ActionKeep {
+			return fmt.Errorf("rule %q has invalid action %q (expected %s or %s)", rule.Name, rule.Action, ActionDelete, ActionKeep)
+		}
+		if rule.Action == ActionDelete && rule.Match.empty() {
+			return fmt.Errorf("rule %q would delete every artifact, a delete rule must match on at least one condition", rule.Name)
+		}
+
+		if len(rule.Match.Pattern) > 0 {
+			re, err := regexp.CompilePOSIX(rule.Match.Pattern)
+			if err != nil {
+				return fmt.Errorf("rule %q has invalid pattern: %w", rule.Name, err)
+			}
+			rule.Match.re = re
+		}
+
+		if len(rule.Match.ActiveDuration) > 0 {
+			duration, err := time.ParseDuration(rule.Match.ActiveDuration)
+			if err != nil || duration <= 0 {
+				return fmt.Errorf("rule %q has invalid active duration %q, see https://golang.org/pkg/time/#ParseDuration", rule.Name, rule.Match.ActiveDuration)
+			}
+			rule.Match.active = duration
+		}
+	}
+
+	return nil
+}
+
+// Evaluate returns the first rule matching the artifact, or nil if no rule matches. Branch and head SHA conditions
+// are matched against the run listed along with the artifact; see EvaluateRun for the other run conditions.
+func (p *Policy) Evaluate(artifact *github.Artifact) *Rule {
+	return p.EvaluateRun(artifact, listedRun(artifact))
+}
+
+// EvaluateRun returns the first rule matching the artifact and the workflow run which produced it, or nil if no
+// rule matches
+func (p *Policy) EvaluateRun(artifact *github.Artifact, run *github.WorkflowRun) *Rule {
+	for _, rule := range p.Rules {
+		if rule.Match.matches(artifact) && rule.Match.runFilter().matches(run) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// needsRuns is true when a rule matches on fields only the workflow run holds
+func (p *Policy) needsRuns() bool {
+	for _, rule := range p.Rules {
+		if rule.Match.runFilter().needsLookup() {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) filterArtifacts(logger log.FieldLogger, artifacts []*github.Artifact, runOf func(*github.Artifact) *github.WorkflowRun) []*github.Artifact {
+	filtered := make([]*github.Artifact, 0)
+	for _, artifact := range artifacts {
+		fields := log.Fields{"id": artifact.GetID(), "name": artifact.GetName(), "size": artifact.GetSizeInBytes()}
+		rule := p.EvaluateRun(artifact, runOf(artifact))
+		if rule == nil {
+			logger.WithFields(fields).Info("Policy: no rule matched, keeping artifact.")
+			continue
+		}
+
+		fields["rule"] = rule.Name
+		fields["action"] = rule.Action
+		logger.WithFields(fields).Info("Policy: rule decided artifact.")
+		if rule.Action == ActionDelete {
+			filtered = append(filtered, artifact)
+		}
+	}
+	return filtered
+}
+
+func (m *Match) runFilter() runFilter {
+	return runFilter{workflow: m.Workflow, branch: m.Branch, headSHA: m.HeadSHA, event: m.Event, conclusion: m.Conclusion}
+}
+
+func (m *Match) matches(artifact *github.Artifact) bool {
+	size := artifact.GetSizeInBytes()
+	if m.MinBytes != nil && size < *m.MinBytes {
+		return false
+	}
+	if m.MaxBytes != nil && size > *m.MaxBytes {
+		return false
+	}
+	if len(m.Name) > 0 && artifact.GetName() != m.Name {
+		return false
+	}
+	if m.re != nil && !m.re.MatchString(artifact.GetName()) {
+		return false
+	}
+	if m.active > 0 && !artifact.GetCreatedAt().Before(time.Now().Add(-m.active)) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	policy, err := LoadPolicy("testdata/policy_example.yml")
+	if err != nil {
+		t.Fatalf("unexpected error loading policy: %v", err)
+	}
+
+	if len(policy.Rules) != 3 {
+		t.Fatalf("expected 3 rules, got %d", len(policy.Rules))
+	}
+
+	expected := []struct {
+		name   string
+		action Action
+	}{
+		{"keep-releases", ActionKeep},
+		{"large-and-stale", ActionDelete},
+		{"coverage", ActionDelete},
+	}
+	for i, e := range expected {
+		if policy.Rules[i].Name != e.name || policy.Rules[i].Action != e.action {
+			t.Errorf("rule %d: expected %s/%s, got %s/%s", i, e.name, e.action, policy.Rules[i].Name, policy.Rules[i].Action)
+		}
+	}
+}
+
+func TestLoadPolicy_MissingFile(t *testing.T) {
+	if _, err := LoadPolicy("testdata/does_not_exist.yml"); err == nil {
+		t.Errorf("expected an error for a missing policy file")
+	}
+}
+
+func TestLoadPolicy_UnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yml")
+	// patern is a typo of pattern, which would otherwise leave the rule matching every artifact
+	content := "rules:\n  - name: stale\n    action: delete\n    match:\n      patern: '^tmp-'\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := LoadPolicy(path); err == nil || !strings.Contains(err.Error(), "patern") {
+		t.Errorf("expected the unknown key to be rejected, got %v", err)
+	}
+}
+
+func TestLoadPolicy_EmptyMatchDeleteRule(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yml")
+	content := "rules:\n  - name: everything\n    action: delete\n    match: {}\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := LoadPolicy(path); err == nil || !strings.Contains(err.Error(), "every artifact") {
+		t.Errorf("expected a delete rule without conditions to be rejected, got %v", err)
+	}
+}
+
+func TestPolicy_Compile(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []*Rule
+		wantErr bool
+	}{
+		{
+			name:    "valid rule",
+			rules:   []*Rule{{Name: "a", Action: ActionDelete, Match: Match{Name: "a"}}},
+			wantErr: false,
+		},
+		{
+			name:    "keep rule matching everything",
+			rules:   []*Rule{{Name: "a", Action: ActionKeep}},
+			wantErr: false,
+		},
+		{
+			name:    "delete rule matching everything",
+			rules:   []*Rule{{Name: "a", Action: ActionDelete}},
+			wantErr: true,
+		},
+		{
+			name:    "no rules",
+			rules:   nil,
+			wantErr: true,
+		},
+		{
+			name:    "missing name",
+			rules:   []*Rule{{Action: ActionDelete, Match: Match{Name: "a"}}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate name",
+			rules:   []*Rule{{Name: "a", Action: ActionDelete, Match: Match{Name: "a"}}, {Name: "a", Action: ActionKeep}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid action",
+			rules:   []*Rule{{Name: "a", Action: "archive"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			rules:   []*Rule{{Name: "a", Action: ActionDelete, Match: Match{Pattern: "[invalid"}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid active duration",
+			rules:   []*Rule{{Name: "a", Action: ActionDelete, Match: Match{ActiveDuration: "3 days"}}},
+			wantErr: true,
+		},
+		{
+			name:    "negative active duration",
+			rules:   []*Rule{{Name: "a", Action: ActionDelete, Match: Match{ActiveDuration: "-1h"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &Policy{Rules: tt.rules}
+			err := policy.compile()
+			if tt.wantErr && err == nil {
+				t.Errorf("expected an error, got none")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	policy, err := LoadPolicy("testdata/policy_example.yml")
+	if err != nil {
+		t.Fatalf("unexpected error loading policy: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		artifact *github.Artifact
+		wantRule string
+	}{
+		{
+			name:     "first match wins over later delete rule",
+			artifact: createArtifact("release-1.0", 100000000, time.Now().Add(-96*time.Hour)),
+			wantRule: "keep-releases",
+		},
+		{
+			name:     "large and stale artifact",
+			artifact: createArtifact("dist", 100000000, time.Now().Add(-96*time.Hour)),
+			wantRule: "large-and-stale",
+		},
+		{
+			name:     "large but active artifact falls through to name rule",
+			artifact: createArtifact("coverage", 100000000, time.Now().Add(-48*time.Hour)),
+			wantRule: "coverage",
+		},
+		{
+			name:     "no rule matches",
+			artifact: createArtifact("coverage", 100, time.Now().Add(-1*time.Hour)),
+			wantRule: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := policy.Evaluate(tt.artifact)
+			got := ""
+			if rule != nil {
+				got = rule.Name
+			}
+			if got != tt.wantRule {
+				t.Errorf("expected rule %q, got %q", tt.wantRule, got)
+			}
+		})
+	}
+}
+
+func TestFilterArtifacts_Policy(t *testing.T) {
+	policy, err := LoadPolicy("testdata/policy_example.yml")
+	if err != nil {
+		t.Fatalf("unexpected error loading policy: %v", err)
+	}
+
+	// MinBytes would exclude everything if the policy did not replace the individual filters
+	app := &App{
+		MinBytes: 1000000000,
+		Policy:   policy,
+	}
+
+	artifacts := []*github.Artifact{
+		createArtifact("release-1.0", 100000000, time.Now().Add(-96*time.Hour)), // kept by keep-releases
+		createArtifact("dist", 100000000, time.Now().Add(-96*time.Hour)),        // deleted by large-and-stale
+		createArtifact("coverage", 100, time.Now().Add(-48*time.Hour)),          // deleted by coverage
+		createArtifact("coverage", 100, time.Now().Add(-1*time.Hour)),           // no match
+	}
+
+	result := app.filterArtifacts(artifacts)
+	if len(result) != 2 {
+		t.Fatalf("expected 2 matching artifacts, got %d", len(result))
+	}
+	if result[0].GetName() != "dist" || result[1].GetName() != "coverage" {
+		t.Errorf("unexpected artifacts in result: %s, %s", result[0].GetName(), result[1].GetName())
+	}
+}
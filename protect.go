@@ -0,0 +1,236 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// TagService is the subset of the GitHub Repositories API used to find tagged commits for protection rules.
+// It is satisfied by the Repositories service of a *github.Client.
+type TagService interface {
+	ListTags(ctx context.Context, owner, repo string, opts *github.ListOptions) ([]*github.RepositoryTag, *github.Response, error)
+}
+
+// WithTagService uses a custom implementation of the tags API
+func WithTagService(service TagService) Option {
+	return func(a *App) {
+		a.tags = service
+	}
+}
+
+const (
+	// ProtectName protects artifacts whose name matches a glob, such as name:release-*
+	ProtectName = "name"
+	// ProtectPattern protects artifacts whose name matches a POSIX regex, such as pattern:^release-
+	ProtectPattern = "pattern"
+	// ProtectRun protects the artifacts of a workflow run, such as run:12345
+	ProtectRun = "run"
+	// ProtectBranch protects the artifacts of runs on branches matching a glob, such as branch:release/*
+	ProtectBranch = "branch"
+	// ProtectTagged protects the artifacts of runs of tagged commits, and takes no value
+	ProtectTagged = "tagged"
+)
+
+// protection is a parsed protection rule
+type protection struct {
+	rule  string
+	kind  string
+	value string
+	re    *regexp.Regexp
+	runID int64
+}
+
+// parseProtection parses a protection rule of the form kind:value. A rule without a kind protects artifact names
+// matching it as a glob, except for tagged.
+func parseProtection(rule string) (*protection, error) {
+	kind, value, found := strings.Cut(rule, ":")
+	if !found {
+		kind, value = ProtectName, rule
+		if rule == ProtectTagged {
+			kind, value = ProtectTagged, ""
+		}
+	}
+
+	p := &protection{rule: rule, kind: kind, value: value}
+	switch kind {
+	case ProtectName, ProtectBranch:
+		if len(value) == 0 {
+			return nil, fmt.Errorf("protection rule %q is missing a glob", rule)
+		}
+	case ProtectPattern:
+		re, err := regexp.CompilePOSIX(value)
+		if err != nil {
+			return nil, fmt.Errorf("protection rule %q has invalid pattern: %w", rule, err)
+		}
+		p.re = re
+	case ProtectRun:
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("protection rule %q has invalid run id", rule)
+		}
+		p.runID = id
+	case ProtectTagged:
+		if len(value) > 0 {
+			return nil, fmt.Errorf("protection rule %q takes no value", rule)
+		}
+	default:
+		return nil, fmt.Errorf("protection rule %q has unknown kind %q (expected %s, %s, %s, %s or %s)",
+			rule, kind, ProtectName, ProtectPattern, ProtectRun, ProtectBranch, ProtectTagged)
+	}
+	return p, nil
+}
+
+// protections parses the Protect rules
+func (a *App) protections() ([]*protection, error) {
+	protections := make([]*protection, 0, len(a.Protect))
+	for _, rule := range a.Protect {
+		p, err := parseProtection(rule)
+		if err != nil {
+			return nil, err
+		}
+		protections = append(protections, p)
+	}
+	return protections, nil
+}
+
+// protectsTagged is true when a Protect rule protects the artifacts of tagged commits
+func (a *App) protectsTagged() bool {
+	for _, rule := range a.Protect {
+		if rule == ProtectTagged || strings.HasPrefix(rule, ProtectTagged+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// protectedArtifact is an artifact matched by the filters, which a protection rule keeps from being deleted
+type protectedArtifact struct {
+	artifact *github.Artifact
+	rule     string
+}
+
+// reason is reported as the outcome of the protected artifact
+func (p protectedArtifact) reason() string {
+	return "protected by rule " + p.rule
+}
+
+// protect separates the artifacts which a protection rule keeps from being deleted. Should the rules be invalid,
+// every artifact is protected.
+func (a *App) protect(artifacts []*github.Artifact) ([]*github.Artifact, []protectedArtifact) {
+	if len(a.Protect) == 0 {
+		return artifacts, nil
+	}
+
+	protections, err := a.protections()
+	if err != nil {
+		a.log().WithError(err).Error("Failed to parse the protection rules. Every artifact is protected.")
+		protections = []*protection{{rule: "invalid"}}
+	}
+
+	kept := make([]*github.Artifact, 0, len(artifacts))
+	protected := make([]protectedArtifact, 0)
+	for _, artifact := range artifacts {
+		if rule, ok := a.protectedBy(protections, artifact); ok {
+			protected = append(protected, protectedArtifact{artifact: artifact, rule: rule})
+			continue
+		}
+		kept = append(kept, artifact)
+	}
+	return kept, protected
+}
+
+// protectedBy returns the first protection rule matching the artifact
+func (a *App) protectedBy(protections []*protection, artifact *github.Artifact) (string, bool) {
+	run := listedRun(artifact)
+	for _, p := range protections {
+		matched := false
+		switch p.kind {
+		case ProtectName:
+			matched = globMatch(p.value, artifact.GetName())
+		case ProtectPattern:
+			matched = p.re.MatchString(artifact.GetName())
+		case ProtectRun:
+			matched = run.GetID() == p.runID
+		case ProtectBranch:
+			matched = globMatch(p.value, run.GetHeadBranch())
+		case ProtectTagged:
+			matched = a.tagged(run.GetHeadSHA())
+		default:
+			// an invalid rule protects every artifact
+			matched = true
+		}
+		if matched {
+			return p.rule, true
+		}
+	}
+	return "", false
+}
+
+// tagged is true when a tag points at the commit. When the tags couldn't be listed, every commit is considered tagged.
+func (a *App) tagged(sha string) bool {
+	if a.runs == nil {
+		return true
+	}
+	tagged, listed := a.runs.tagsOf(*a.Owner + "/" + *a.Repo)
+	if !listed || tagged == nil {
+		return true
+	}
+	return tagged[sha]
+}
+
+// resolveTags lists the tagged commits of the repository once
+func (a *App) resolveTags(ctx context.Context) {
+	key := *a.Owner + "/" + *a.Repo
+	if _, listed := a.runs.tagsOf(key); listed {
+		return
+	}
+	if a.tags == nil {
+		a.log().Error("Protecting tagged commits requires a TagService. Every artifact is protected.")
+		a.runs.putTagsOf(key, nil)
+		return
+	}
+
+	tagged := make(map[string]bool)
+	gate := &rateGate{}
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var tags []*github.RepositoryTag
+		requestContext, cancel := within(ctx, a.Timeouts.Request)
+		resp, err := a.withRetry(requestContext, gate, func(ctx context.Context) (resp *github.Response, err error) {
+			tags, resp, err = a.tags.ListTags(ctx, *a.Owner, *a.Repo, opts)
+			return resp, err
+		})
+		cancel()
+		if err != nil {
+			a.log().WithError(err).Error("Unable to list the tags of the repository. Every artifact is protected.")
+			a.runs.putTagsOf(key, nil)
+			return
+		}
+		for _, tag := range tags {
+			tagged[tag.GetCommit().GetSHA()] = true
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	a.log().WithFields(log.Fields{"commits": len(tagged)}).Debug("Protecting the artifacts of tagged commits.")
+	a.runs.putTagsOf(key, tagged)
+}
+
+// reportProtected records the protected artifacts as skipped, or only logs them without a report
+func (a *App) reportProtected(protected []protectedArtifact, report *Report) {
+	for _, p := range protected {
+		a.log().WithFields(log.Fields{"id": p.artifact.GetID(), "name": p.artifact.GetName(), "rule": p.rule}).
+			Info("Protected artifact will not be deleted.")
+		if report != nil {
+			report.skipped(p.artifact, p.reason())
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+func TestParseProtection(t *testing.T) {
+	tests := []struct {
+		rule    string
+		kind    string
+		wantErr bool
+	}{
+		{"release-*", ProtectName, false},
+		{"name:release-*", ProtectName, false},
+		{"pattern:^coverage-[0-9]+$", ProtectPattern, false},
+		{"run:12345", ProtectRun, false},
+		{"branch:release/*", ProtectBranch, false},
+		{"tagged", ProtectTagged, false},
+		{"name:", "", true},
+		{"pattern:[", "", true},
+		{"run:abc", "", true},
+		{"run:-1", "", true},
+		{"branch:", "", true},
+		{"tagged:v1", "", true},
+		{"label:keep", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			p, err := parseProtection(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProtection(%q) error = %v, wantErr %v", tt.rule, err, tt.wantErr)
+			}
+			if err == nil && p.kind != tt.kind {
+				t.Errorf("parseProtection(%q) kind = %q, want %q", tt.rule, p.kind, tt.kind)
+			}
+		})
+	}
+}
+
+func TestRun_Protect(t *testing.T) {
+	tagged := createRun(1, "Release", ".github/workflows/release.yml", "main", "aaaa111", "push", "success")
+	release := createRun(2, "CI", ".github/workflows/ci.yml", "release/1.x", "bbbb222", "push", "success")
+	main := createRun(3, "CI", ".github/workflows/ci.yml", "main", "cccc333", "push", "success")
+	pinned := createRun(4, "CI", ".github/workflows/ci.yml", "main", "dddd444", "push", "success")
+
+	notes := createArtifactOfRun(3, main, time.Hour)
+	notes.Name = github.Ptr("release-notes")
+	coverage := createArtifactOfRun(4, main, time.Hour)
+	coverage.Name = github.Ptr("coverage-42")
+	artifacts := []*github.Artifact{
+		createArtifactOfRun(1, tagged, time.Hour),
+		createArtifactOfRun(2, release, time.Hour),
+		notes,
+		coverage,
+		createArtifactOfRun(5, pinned, time.Hour),
+		createArtifactOfRun(6, main, time.Hour),
+	}
+	wantRules := map[int64]string{
+		1: "tagged",
+		2: "branch:release/*",
+		3: "release-*",
+		4: "pattern:^coverage-",
+		5: "run:4",
+	}
+
+	tests := []struct {
+		name   string
+		budget *int64
+	}{
+		{"streamed", nil},
+		{"budget", github.Ptr(int64(0))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakegithub.NewServer()
+			defer server.Close()
+			server.AddArtifacts("octo-org", "octo-docs", artifacts...)
+			server.AddTags("octo-org", "octo-docs", &github.RepositoryTag{Name: github.Ptr("v1.0.0"), Commit: &github.Commit{SHA: github.Ptr("aaaa111")}})
+
+			app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+			app.MinBytes = 0
+			app.Budget = tt.budget
+			app.Protect = []string{"release-*", "pattern:^coverage-", "run:4", "branch:release/*", "tagged"}
+			report, err := app.Run(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			deleted := server.Deleted("octo-org", "octo-docs")
+			if len(deleted) != 1 || deleted[0] != 6 {
+				t.Errorf("expected only the unprotected artifact to be deleted, got %v", deleted)
+			}
+			if report.Matched != len(artifacts) || report.Skipped != len(wantRules) {
+				t.Errorf("expected %d matched and %d skipped, got %+v", len(artifacts), len(wantRules), report)
+			}
+			for _, outcome := range report.Outcomes {
+				rule, ok := wantRules[outcome.Artifact.GetID()]
+				if !ok {
+					continue
+				}
+				if outcome.Status != StatusSkipped || outcome.Reason != "protected by rule "+rule {
+					t.Errorf("expected artifact %d to be skipped as protected by rule %s, got %+v", outcome.Artifact.GetID(), rule, outcome)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_ProtectTaggedFailsSafe(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	run := createRun(1, "CI", ".github/workflows/ci.yml", "main", "aaaa111", "push", "success")
+	server.AddArtifacts("octo-org", "octo-docs", createArtifactOfRun(1, run, time.Hour), createArtifactOfRun(2, run, time.Hour))
+	server.InjectFailure(fakegithub.Failure{Method: http.MethodGet, Path: "/repos/octo-org/octo-docs/tags", Status: http.StatusForbidden})
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+	app.MinBytes = 0
+	app.Protect = []string{"tagged"}
+	report, err := app.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted := server.Deleted("octo-org", "octo-docs"); len(deleted) != 0 || report.Skipped != 2 {
+		t.Errorf("expected every artifact to be protected when the tags can't be listed, got %v deleted and %+v", deleted, report)
+	}
+}
+
+func TestRun_ProtectInvalidRule(t *testing.T) {
+	app, _ := NewWithOptions(WithArtifactService(&fakeArtifactService{}), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+	app.Protect = []string{"label:keep"}
+	if _, err := app.Run(context.Background()); err == nil {
+		t.Error("expected an invalid protection rule to fail the run")
+	}
+}
+
+func TestApply_Protect(t *testing.T) {
+	artifact := createServedArtifact(1, "coverage", 1000, 10)
+	service := &fakeArtifactService{artifacts: []*github.Artifact{artifact}, perPage: 10}
+	planner, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+	planner.ActiveDuration = "30m"
+	plan, err := planner.Plan(context.Background())
+	if err != nil || len(plan.Artifacts) != 1 {
+		t.Fatalf("expected one planned artifact, got %v (%v)", plan, err)
+	}
+
+	// rules given when applying protect planned artifacts, along with those of the plan
+	applier, _ := NewWithOptions(WithArtifactService(service), WithLogger(quietLogger()))
+	applier.Protect = []string{"run:10"}
+	report, err := applier.Apply(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(service.deleted) != 0 || report.Skipped != 1 || report.Outcomes[0].Reason != "protected by rule run:10" {
+		t.Errorf("expected the planned artifact to be protected, got %+v", report)
+	}
+}
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// PullRequestService is the subset of the GitHub Pull Requests API used to find the pull requests built by workflow
+// runs for ClosedPRs. It is satisfied by the PullRequests service of a *github.Client.
+type PullRequestService interface {
+	Get(ctx context.Context, owner, repo string, number int) (*github.PullRequest, *github.Response, error)
+	List(ctx context.Context, owner, repo string, opts *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error)
+}
+
+// WithPullRequestService uses a custom implementation of the pull requests API
+func WithPullRequestService(service PullRequestService) Option {
+	return func(a *App) {
+		a.pullRequests = service
+	}
+}
+
+// pullRequestEvents are the events of workflow runs which build pull requests
+var pullRequestEvents = map[string]bool{"pull_request": true, "pull_request_target": true}
+
+// resolvePullRequests finds the pull requests built by the runs which produced artifacts, and looks up those which
+// aren't cached yet. The runs must have been resolved already.
+func (a *App) resolvePullRequests(ctx context.Context, artifacts []*github.Artifact) {
+	if a.pullRequests == nil || a.runs == nil {
+		return
+	}
+	gate := &rateGate{}
+
+	runs := make([]*github.WorkflowRun, 0)
+	seen := make(map[int64]bool)
+	for _, artifact := range artifacts {
+		run := a.runOf(artifact)
+		if _, found := a.runs.pullsOf(run.GetID()); !pullRequestEvents[run.GetEvent()] || found || seen[run.GetID()] {
+			continue
+		}
+		seen[run.GetID()] = true
+		runs = append(runs, run)
+	}
+	a.concurrently(ctx, len(runs), func(i int) {
+		a.findPullRequests(ctx, gate, runs[i])
+	})
+
+	numbers := make([]int, 0)
+	wanted := make(map[int]bool)
+	for _, run := range runs {
+		found, _ := a.runs.pullsOf(run.GetID())
+		for _, number := range found {
+			if _, cached := a.runs.pull(a.pullKey(number)); !cached && !wanted[number] {
+				wanted[number] = true
+				numbers = append(numbers, number)
+			}
+		}
+	}
+	if len(numbers) > 0 {
+		a.log().WithFields(log.Fields{"pulls": len(numbers)}).Debug("Looking up pull requests.")
+	}
+	a.concurrently(ctx, len(numbers), func(i int) {
+		a.resolvePullRequest(ctx, gate, numbers[i])
+	})
+}
+
+// findPullRequests records the numbers of the pull requests a run built. Runs of pull requests from forks don't list
+// their pull requests, which are found by the head branch instead.
+func (a *App) findPullRequests(ctx context.Context, gate *rateGate, run *github.WorkflowRun) {
+	numbers := make([]int, 0, len(run.PullRequests))
+	for _, pull := range run.PullRequests {
+		numbers = append(numbers, pull.GetNumber())
+	}
+	if len(numbers) > 0 {
+		a.runs.putPullsOf(run.GetID(), numbers)
+		return
+	}
+
+	ctx, cancel := within(ctx, a.Timeouts.Request)
+	defer cancel()
+
+	headOwner := run.GetHeadRepository().GetOwner().GetLogin()
+	if len(headOwner) == 0 {
+		headOwner = *a.Owner
+	}
+	opts := &github.PullRequestListOptions{State: "all", Head: headOwner + ":" + run.GetHeadBranch(), ListOptions: github.ListOptions{PerPage: 100}}
+	var pulls []*github.PullRequest
+	_, err := a.withRetry(ctx, gate, func(ctx context.Context) (resp *github.Response, err error) {
+		pulls, resp, err = a.pullRequests.List(ctx, *a.Owner, *a.Repo, opts)
+		return resp, err
+	})
+	if err != nil {
+		a.log().WithError(err).WithFields(log.Fields{"runId": run.GetID(), "head": opts.Head}).
+			Warn("Unable to find the pull requests of the workflow run. Its artifacts will not match closed pull requests.")
+		return
+	}
+
+	// every pull request of the branch must be closed, so a branch reopened in a new pull request keeps its artifacts
+	for _, pull := range pulls {
+		numbers = append(numbers, pull.GetNumber())
+		a.runs.putPull(a.pullKey(pull.GetNumber()), pull)
+	}
+	a.runs.putPullsOf(run.GetID(), numbers)
+}
+
+func (a *App) resolvePullRequest(ctx context.Context, gate *rateGate, number int) {
+	ctx, cancel := within(ctx, a.Timeouts.Request)
+	defer cancel()
+
+	var pull *github.PullRequest
+	resp, err := a.withRetry(ctx, gate, func(ctx context.Context) (resp *github.Response, err error) {
+		pull, resp, err = a.pullRequests.Get(ctx, *a.Owner, *a.Repo, number)
+		return resp, err
+	})
+	switch {
+	case err != nil && statusCode(resp) == http.StatusNotFound:
+		a.log().WithFields(log.Fields{"pull": number}).Debug("Pull request no longer exists.")
+		a.runs.putPull(a.pullKey(number), nil)
+	case err != nil:
+		a.log().WithError(err).WithFields(log.Fields{"pull": number}).Warn("Unable to look up the pull request. Its artifacts will not match closed pull requests.")
+	default:
+		a.runs.putPull(a.pullKey(number), pull)
+	}
+}
+
+// pullKey identifies a pull request of the repository in the cache, which is shared by the repositories of an org
+func (a *App) pullKey(number int) string {
+	return fmt.Sprintf("%s/%s#%d", *a.Owner, *a.Repo, number)
+}
+
+// closedPullRequests is true when a run built pull requests which are all closed, for at least ClosedPRGrace.
+// Runs whose pull requests couldn't be found are never considered closed.
+func (a *App) closedPullRequests(run *github.WorkflowRun) bool {
+	if !pullRequestEvents[run.GetEvent()] || a.runs == nil {
+		return false
+	}
+	numbers, found := a.runs.pullsOf(run.GetID())
+	if !found || len(numbers) == 0 {
+		return false
+	}
+	for _, number := range numbers {
+		pull, cached := a.runs.pull(a.pullKey(number))
+		if !cached || pull == nil || pull.GetState() != "closed" {
+			return false
+		}
+		if time.Since(pull.GetClosedAt().Time) < a.ClosedPRGrace {
+			return false
+		}
+	}
+	return true
+}
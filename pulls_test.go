@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+func createPullRequest(number int, state, head string, closedAgo time.Duration) *github.PullRequest {
+	pull := &github.PullRequest{Number: &number, State: &state, Head: &github.PullRequestBranch{Label: &head}}
+	if state == "closed" {
+		pull.ClosedAt = &github.Timestamp{Time: time.Now().Add(-closedAgo)}
+	}
+	return pull
+}
+
+func TestRun_ClosedPRs(t *testing.T) {
+	merged := createRun(1, "Preview", ".github/workflows/preview.yml", "docs", "aaaa111", "pull_request", "success")
+	merged.PullRequests = []*github.PullRequest{{Number: github.Ptr(1)}}
+	open := createRun(2, "Preview", ".github/workflows/preview.yml", "wip", "bbbb222", "pull_request", "success")
+	open.PullRequests = []*github.PullRequest{{Number: github.Ptr(2)}}
+	// runs of pull requests from forks don't list their pull requests
+	fork := createRun(3, "Preview", ".github/workflows/preview.yml", "patch", "cccc333", "pull_request_target", "success")
+	fork.HeadRepository = &github.Repository{Owner: &github.User{Login: github.Ptr("octocat")}}
+	push := createRun(4, "CI", ".github/workflows/ci.yml", "main", "dddd444", "push", "success")
+	missing := createRun(5, "Preview", ".github/workflows/preview.yml", "gone", "eeee555", "pull_request", "success")
+	missing.PullRequests = []*github.PullRequest{{Number: github.Ptr(9)}}
+
+	tests := []struct {
+		name        string
+		grace       time.Duration
+		wantDeleted []int64
+	}{
+		{"closed", 0, []int64{1, 2, 4}},
+		{"grace period", 24 * time.Hour, []int64{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakegithub.NewServer()
+			defer server.Close()
+			server.AddWorkflowRuns("octo-org", "octo-docs", merged, open, fork, push, missing)
+			server.AddPullRequests("octo-org", "octo-docs",
+				createPullRequest(1, "closed", "octo-org:docs", 10*24*time.Hour),
+				createPullRequest(2, "open", "octo-org:wip", 0),
+				createPullRequest(3, "closed", "octocat:patch", time.Hour),
+			)
+			server.AddArtifacts("octo-org", "octo-docs",
+				createServedArtifact(1, "preview", 100, 1),
+				createServedArtifact(2, "preview", 100, 1),
+				createServedArtifact(3, "preview", 100, 2),
+				createServedArtifact(4, "preview", 100, 3),
+				createServedArtifact(5, "dist", 100, 4),
+				createServedArtifact(6, "preview", 100, 5),
+			)
+
+			app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+			app.MinBytes = 0
+			app.ClosedPRs = true
+			app.ClosedPRGrace = tt.grace
+			report, err := app.Run(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			deleted := server.Deleted("octo-org", "octo-docs")
+			sort.Slice(deleted, func(i, j int) bool { return deleted[i] < deleted[j] })
+			if len(deleted) != len(tt.wantDeleted) || report.Matched != len(tt.wantDeleted) {
+				t.Fatalf("expected %v to be deleted, got %v", tt.wantDeleted, deleted)
+			}
+			for i := range deleted {
+				if deleted[i] != tt.wantDeleted[i] {
+					t.Fatalf("expected %v to be deleted, got %v", tt.wantDeleted, deleted)
+				}
+			}
+
+			counts := make(map[string]int)
+			for _, request := range server.Requests() {
+				counts[request]++
+			}
+			if counts["GET /repos/octo-org/octo-docs/pulls/1"] != 1 || counts["GET /repos/octo-org/octo-docs/actions/runs/1"] != 1 {
+				t.Errorf("expected the run and pull request to be looked up once, got %v", counts)
+			}
+			// the pull request found by its head branch isn't looked up again
+			if counts["GET /repos/octo-org/octo-docs/pulls/3"] != 0 {
+				t.Errorf("expected the pull request of the fork to be found by its head, got %v", counts)
+			}
+		})
+	}
+}
+
+func TestRun_ClosedPRsPreconditions(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(a *App)
+	}{
+		{"negative grace", func(a *App) { a.ClosedPRGrace = -time.Hour }},
+		{"with policy", func(a *App) { a.Policy = &Policy{} }},
+		{"without service", func(a *App) { a.pullRequests = nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakegithub.NewServer()
+			defer server.Close()
+			app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+			app.ClosedPRs = true
+			tt.configure(app)
+			if _, err := app.Run(context.Background()); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
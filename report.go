@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// Status is the outcome of a single artifact
+type Status string
+
+const (
+	// StatusDeleted artifacts were deleted, or were already gone
+	StatusDeleted Status = "deleted"
+	// StatusFailed artifacts could not be deleted
+	StatusFailed Status = "failed"
+	// StatusSkipped artifacts matched, but were intentionally not deleted
+	StatusSkipped Status = "skipped"
+	// StatusDownloaded artifacts were downloaded by Download
+	StatusDownloaded Status = "downloaded"
+)
+
+// Result summarizes a Report as a whole
+type Result int
+
+const (
+	// ResultNothingToDo means no artifacts matched
+	ResultNothingToDo Result = iota
+	// ResultSuccess means every matched artifact was deleted or intentionally skipped
+	ResultSuccess
+	// ResultPartialFailure means at least one matched artifact could not be deleted
+	ResultPartialFailure
+	// ResultCancelled means the run was cancelled, so the report is partial
+	ResultCancelled
+)
+
+// Outcome is what happened to a single matched artifact
+type Outcome struct {
+	Owner    string
+	Repo     string
+	Artifact *github.Artifact
+	Status   Status
+	// Reason explains why an artifact was skipped
+	Reason string
+	// Err is the error which failed the deletion
+	Err error
+}
+
+// Report is the result of a run. Reports of an org sweep hold the report of each repository.
+type Report struct {
+	Owner          string
+	Repo           string
+	Matched        int
+	Deleted        int
+	Failed         int
+	Skipped        int
+	Downloaded     int
+	BytesReclaimed int64
+	Outcomes       []*Outcome
+	Repositories   []*Report
+	// Err is the error which ended the run of a repository early
+	Err error
+
+	mu sync.Mutex
+}
+
+func newReport(owner, repo string) *Report {
+	return &Report{Owner: owner, Repo: repo, Outcomes: make([]*Outcome, 0)}
+}
+
+// Result summarizes the report
+func (r *Report) Result() Result {
+	if errors.Is(r.Err, context.Canceled) {
+		return ResultCancelled
+	}
+	for _, repository := range r.Repositories {
+		if repository.Result() == ResultPartialFailure {
+			return ResultPartialFailure
+		}
+	}
+
+	switch {
+	case r.Failed > 0 || r.Err != nil:
+		return ResultPartialFailure
+	case r.Matched == 0:
+		return ResultNothingToDo
+	default:
+		return ResultSuccess
+	}
+}
+
+func (r *Report) deleted(artifact *github.Artifact) {
+	r.record(&Outcome{Artifact: artifact, Status: StatusDeleted})
+}
+
+func (r *Report) failed(artifact *github.Artifact, err error) {
+	r.record(&Outcome{Artifact: artifact, Status: StatusFailed, Err: err})
+}
+
+func (r *Report) skipped(artifact *github.Artifact, reason string) {
+	r.record(&Outcome{Artifact: artifact, Status: StatusSkipped, Reason: reason})
+}
+
+func (r *Report) downloaded(artifact *github.Artifact) {
+	r.record(&Outcome{Artifact: artifact, Status: StatusDownloaded})
+}
+
+// addMatched counts artifacts slated for deletion, which may be found a page at a time
+func (r *Report) addMatched(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Matched += n
+}
+
+func (r *Report) record(outcome *Outcome) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	outcome.Owner = r.Owner
+	outcome.Repo = r.Repo
+	r.Outcomes = append(r.Outcomes, outcome)
+	switch outcome.Status {
+	case StatusDeleted:
+		r.Deleted++
+		r.BytesReclaimed += outcome.Artifact.GetSizeInBytes()
+	case StatusFailed:
+		r.Failed++
+	case StatusSkipped:
+		r.Skipped++
+	case StatusDownloaded:
+		r.Downloaded++
+	}
+}
+
+// merge adds the report of a repository to an org report
+func (r *Report) merge(repository *Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Repositories = append(r.Repositories, repository)
+	r.Matched += repository.Matched
+	r.Deleted += repository.Deleted
+	r.Failed += repository.Failed
+	r.Skipped += repository.Skipped
+	r.Downloaded += repository.Downloaded
+	r.BytesReclaimed += repository.BytesReclaimed
+	r.Outcomes = append(r.Outcomes, repository.Outcomes...)
+}
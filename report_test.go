@@ -0,0 +1,155 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+func TestReport_Result(t *testing.T) {
+	artifact := createServedArtifact(1, "a", 100, 1)
+
+	tests := []struct {
+		name   string
+		report func() *Report
+		want   Result
+	}{
+		{
+			name:   "nothing matched",
+			report: func() *Report { return newReport("o", "r") },
+			want:   ResultNothingToDo,
+		},
+		{
+			name: "all deleted",
+			report: func() *Report {
+				r := newReport("o", "r")
+				r.Matched = 1
+				r.deleted(artifact)
+				return r
+			},
+			want: ResultSuccess,
+		},
+		{
+			name: "dry run",
+			report: func() *Report {
+				r := newReport("o", "r")
+				r.Matched = 1
+				r.skipped(artifact, "dry run")
+				return r
+			},
+			want: ResultSuccess,
+		},
+		{
+			name: "partial failure",
+			report: func() *Report {
+				r := newReport("o", "r")
+				r.Matched = 2
+				r.deleted(artifact)
+				r.failed(artifact, errors.New("boom"))
+				return r
+			},
+			want: ResultPartialFailure,
+		},
+		{
+			name: "failed repository of an org",
+			report: func() *Report {
+				r := newReport("o", "")
+				failed := newReport("o", "r")
+				failed.Err = errors.New("boom")
+				r.merge(failed)
+				return r
+			},
+			want: ResultPartialFailure,
+		},
+		{
+			name: "cancelled",
+			report: func() *Report {
+				r := newReport("o", "r")
+				r.Matched = 2
+				r.failed(artifact, errors.New("boom"))
+				r.skipped(artifact, reasonCancelled)
+				r.Err = fmt.Errorf("listing: %w", context.Canceled)
+				return r
+			},
+			want: ResultCancelled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.report().Result(); got != tt.want {
+				t.Errorf("expected result %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReport_Record(t *testing.T) {
+	r := newReport("octo-org", "octo-docs")
+	r.deleted(createServedArtifact(1, "a", 100, 1))
+	r.deleted(createServedArtifact(2, "b", 50, 1))
+	r.failed(createServedArtifact(3, "c", 10, 1), errors.New("boom"))
+	r.skipped(createServedArtifact(4, "d", 10, 1), "dry run")
+
+	if r.Deleted != 2 || r.Failed != 1 || r.Skipped != 1 || r.BytesReclaimed != 150 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if len(r.Outcomes) != 4 || r.Outcomes[0].Owner != "octo-org" || r.Outcomes[0].Repo != "octo-docs" {
+		t.Errorf("expected outcomes to record the repository")
+	}
+	if r.Outcomes[2].Err == nil || r.Outcomes[3].Reason != "dry run" {
+		t.Errorf("expected outcomes to record the error and reason")
+	}
+}
+
+func TestRun_ReportsOutcomes(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", createServedArtifact(1, "a", 100, 1), createServedArtifact(2, "b", 200, 1))
+	server.InjectFailure(fakegithub.Failure{Method: http.MethodDelete, Path: "/repos/octo-org/octo-docs/actions/artifacts/2", Status: http.StatusForbidden})
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+
+	report, err := app.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Matched != 2 || report.Deleted != 1 || report.Failed != 1 || report.BytesReclaimed != 100 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if report.Result() != ResultPartialFailure {
+		t.Errorf("expected a partial failure")
+	}
+	for _, outcome := range report.Outcomes {
+		if outcome.Artifact.GetID() == 2 && (outcome.Status != StatusFailed || outcome.Err == nil) {
+			t.Errorf("expected artifact 2 to fail with an error, got %+v", outcome)
+		}
+	}
+}
+
+func TestRun_ReportsOrgRepositories(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddRepositories("octo-org", createRepository("api", "private", false), createRepository("web", "public", false))
+	server.AddArtifacts("octo-org", "api", createServedArtifact(1, "a", 100, 1))
+	server.AddArtifacts("octo-org", "web", createServedArtifact(2, "a", 100, 1))
+	server.InjectFailure(fakegithub.Failure{Path: "/repos/octo-org/web/actions/artifacts", Status: http.StatusNotFound})
+
+	app := newServedApp(t, server)
+	app.Org = "octo-org"
+
+	report, err := app.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Repositories) != 2 || report.Deleted != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Repositories[1].Err == nil || report.Result() != ResultPartialFailure {
+		t.Errorf("expected the failed repository to fail the org report")
+	}
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryPolicy controls how failed API calls are retried with exponential backoff.
+// Server errors (5xx), 429 responses, rate limits and network errors are retried; other errors are not.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first. Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubling on every subsequent retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts, before jitter
+	MaxDelay time.Duration
+	// Jitter adds up to this fraction of the delay at random, to spread out retries of concurrent calls
+	Jitter float64
+}
+
+// DefaultRetryPolicy retries up to 3 attempts, starting at one second
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// delay returns the backoff following the given (1-based) failed attempt
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		d += time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// isRetryable reports whether a failed call may succeed if attempted again
+func isRetryable(resp *github.Response, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if isRateLimitError(err) {
+		return true
+	}
+	status := statusCode(resp)
+	// no response at all is a network error
+	return status == 0 || status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
+
+// withRetry invokes call until it succeeds, fails with an error which isn't retryable, or exhausts the retry policy.
+// Rate limits close the gate until they reset and don't count as attempts, up to maxRateLimitWaits times.
+func (a *App) withRetry(ctx context.Context, gate *rateGate, call func(ctx context.Context) (*github.Response, error)) (*github.Response, error) {
+	rateLimitWaits := 0
+	attempt := 1
+	for {
+		if err := gate.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := call(ctx)
+		until, limited := rateLimitedUntil(resp, err)
+		if limited {
+			a.log().WithFields(log.Fields{"until": until.Format(time.RFC3339)}).Warn("Rate limited, pausing requests.")
+			gate.pause(until)
+		}
+		if err == nil {
+			return resp, nil
+		}
+
+		if isRateLimitError(err) && rateLimitWaits < maxRateLimitWaits {
+			rateLimitWaits++
+			continue
+		}
+		if attempt >= a.Retry.attempts() || !isRetryable(resp, err) {
+			return resp, err
+		}
+
+		delay := a.Retry.delay(attempt)
+		a.log().WithError(err).WithFields(log.Fields{"attempt": attempt, "delay": delay}).Warn("Request failed, retrying.")
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, err
+		case <-timer.C:
+		}
+		attempt++
+	}
+}
@@ -0,0 +1,161 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if got := policy.delay(i + 1); got != want {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, want, got)
+		}
+	}
+}
+
+func TestRetryPolicy_DelayJitter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if got := policy.delay(1); got < time.Second || got > 1500*time.Millisecond {
+			t.Fatalf("expected a delay within [1s, 1.5s], got %s", got)
+		}
+	}
+}
+
+func TestRetryPolicy_Attempts(t *testing.T) {
+	if (RetryPolicy{}).attempts() != 1 {
+		t.Errorf("expected the zero policy to make a single attempt")
+	}
+	if DefaultRetryPolicy().attempts() != 3 {
+		t.Errorf("expected the default policy to make 3 attempts")
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	response := func(status int) *github.Response {
+		return &github.Response{Response: &http.Response{StatusCode: status}}
+	}
+
+	tests := []struct {
+		name string
+		resp *github.Response
+		err  error
+		want bool
+	}{
+		{"bad gateway", response(http.StatusBadGateway), errors.New("502"), true},
+		{"internal server error", response(http.StatusInternalServerError), errors.New("500"), true},
+		{"too many requests", response(http.StatusTooManyRequests), errors.New("429"), true},
+		{"secondary rate limit", response(http.StatusForbidden), &github.AbuseRateLimitError{}, true},
+		{"network error", nil, errors.New("connection reset"), true},
+		{"forbidden", response(http.StatusForbidden), errors.New("403"), false},
+		{"not found", response(http.StatusNotFound), errors.New("404"), false},
+		{"canceled", nil, context.Canceled, false},
+		{"deadline exceeded", nil, context.DeadlineExceeded, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.resp, tt.err); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func countRequests(server *fakegithub.Server, prefix string) int {
+	count := 0
+	for _, r := range server.Requests() {
+		if strings.HasPrefix(r, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRun_RetriesTransientListFailure(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", createServedArtifact(1, "a", 100, 1))
+	server.InjectFailure(fakegithub.Failure{Method: http.MethodGet, Path: "/repos/octo-org/octo-docs/actions/artifacts", Status: http.StatusBadGateway, Times: 2})
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+
+	if _, err := app.Run(context.Background()); err != nil {
+		t.Fatalf("expected the run to recover from transient 502s, got %v", err)
+	}
+	if len(server.Deleted("octo-org", "octo-docs")) != 1 {
+		t.Errorf("expected the artifact to be deleted")
+	}
+}
+
+func TestRun_RetriesTransientDeleteFailure(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", createServedArtifact(1, "a", 100, 1))
+	server.InjectFailure(fakegithub.Failure{Method: http.MethodDelete, Path: "/repos/octo-org/octo-docs/actions/artifacts/1", Status: http.StatusServiceUnavailable, Times: 2})
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+
+	report, err := app.runRepo(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Deleted != 1 || report.Failed != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if n := countRequests(server, "DELETE"); n != 3 {
+		t.Errorf("expected 3 delete attempts, got %d", n)
+	}
+}
+
+func TestRun_DoesNotRetryClientErrors(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", createServedArtifact(1, "a", 100, 1))
+	server.InjectFailure(fakegithub.Failure{Method: http.MethodDelete, Path: "/repos/octo-org/octo-docs/actions/artifacts/1", Status: http.StatusForbidden})
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+
+	report, err := app.runRepo(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Failed != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if n := countRequests(server, "DELETE"); n != 1 {
+		t.Errorf("expected a single delete attempt, got %d", n)
+	}
+}
+
+func TestDeleteArtifact_NotFoundIsDeleted(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+	if err := app.deleteArtifact(context.Background(), &rateGate{}, createServedArtifact(1, "a", 100, 1)); err != nil {
+		t.Errorf("expected an artifact which is already gone to be deleted, got %v", err)
+	}
+}
+
+func TestWithRetry_ExhaustsAttempts(t *testing.T) {
+	app := &App{Retry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, logger: quietLogger()}
+	calls := 0
+	_, err := app.withRetry(context.Background(), &rateGate{}, func(ctx context.Context) (*github.Response, error) {
+		calls++
+		return nil, errors.New("connection reset")
+	})
+	if err == nil || calls != 3 {
+		t.Errorf("expected 3 failed attempts, got %d (%v)", calls, err)
+	}
+}
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+// Helper to create a test artifact belonging to a workflow run
+func createServedArtifact(id int64, name string, sizeInBytes int64, runID int64) *github.Artifact {
+	artifact := createArtifact(name, sizeInBytes, time.Now().Add(-1*time.Hour))
+	artifact.ID = &id
+	artifact.WorkflowRun = &github.ArtifactWorkflowRun{ID: &runID}
+	return artifact
+}
+
+func newServedApp(t *testing.T, server *fakegithub.Server, options ...Option) *App {
+	t.Helper()
+	fastRetries := WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	options = append([]Option{WithClient(server.GitHubClient()), WithLogger(quietLogger()), fastRetries}, options...)
+	app, err := NewWithOptions(options...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return app
+}
+
+func TestRun_FakeServer(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	for i := int64(1); i <= 250; i++ {
+		size := int64(10)
+		if i%2 == 0 {
+			size = 1000
+		}
+		server.AddArtifacts("octo-org", "octo-docs", createServedArtifact(i, "artifact", size, 1))
+	}
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+	app.MinBytes = 100
+
+	if _, err := app.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if deleted := server.Deleted("octo-org", "octo-docs"); len(deleted) != 125 {
+		t.Errorf("expected 125 deletions across 3 pages, got %d", len(deleted))
+	}
+	for _, remaining := range server.Artifacts("octo-org", "octo-docs") {
+		if remaining.GetSizeInBytes() >= 100 {
+			t.Errorf("expected artifact %d to be deleted", remaining.GetID())
+		}
+	}
+}
+
+func TestRun_FakeServerFixture(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	if err := server.LoadFixture("octo-org", "octo-docs", "testdata/artifact_example.json"); err != nil {
+		t.Fatalf("unexpected error loading fixture: %v", err)
+	}
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+	app.Name = "Rails"
+
+	if _, err := app.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted := server.Deleted("octo-org", "octo-docs"); len(deleted) != 1 || deleted[0] != 11 {
+		t.Errorf("expected artifact 11 to be deleted, got %v", deleted)
+	}
+}
+
+func TestRun_FakeServerRunId(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs",
+		createServedArtifact(1, "a", 100, 10),
+		createServedArtifact(2, "b", 100, 20),
+		createServedArtifact(3, "c", 100, 10),
+	)
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+	app.RunId = int64Ptr(10)
+
+	if _, err := app.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	remaining := server.Artifacts("octo-org", "octo-docs")
+	if len(remaining) != 1 || remaining[0].GetID() != 2 {
+		t.Errorf("expected only the artifact of run 20 to remain, got %v", remaining)
+	}
+}
+
+func TestRun_FakeServerListError(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", createServedArtifact(1, "a", 100, 1))
+	server.InjectFailure(fakegithub.Failure{Path: "/repos/octo-org/octo-docs/actions/artifacts", Status: http.StatusInternalServerError})
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+
+	if _, err := app.Run(context.Background()); err == nil {
+		t.Errorf("expected the listing error to fail the run")
+	}
+	if len(server.Deleted("octo-org", "octo-docs")) != 0 {
+		t.Errorf("expected no deletions")
+	}
+}
+
+func TestRun_FakeServerDeleteError(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddArtifacts("octo-org", "octo-docs", createServedArtifact(1, "a", 100, 1), createServedArtifact(2, "b", 100, 1))
+	server.InjectFailure(fakegithub.Failure{Method: http.MethodDelete, Path: "/repos/octo-org/octo-docs/actions/artifacts/1", Status: http.StatusInternalServerError})
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+
+	report, err := app.runRepo(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Deleted != 1 || report.Failed != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestRun_FakeServerOrg(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	server.AddRepositories("octo-org",
+		createRepository("api", "private", false),
+		createRepository("web", "public", false),
+		createRepository("old", "private", true),
+	)
+	for i, repo := range []string{"api", "web", "old"} {
+		server.AddArtifacts("octo-org", repo, createServedArtifact(int64(i+1), "a", 100, 1))
+	}
+
+	app := newServedApp(t, server)
+	app.Org = "octo-org"
+	app.Visibility = "private"
+
+	if _, err := app.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(server.Deleted("octo-org", "api")) != 1 {
+		t.Errorf("expected the artifact of api to be deleted")
+	}
+	if len(server.Deleted("octo-org", "web")) != 0 || len(server.Deleted("octo-org", "old")) != 0 {
+		t.Errorf("expected public and archived repositories to be skipped")
+	}
+}
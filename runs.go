@@ -0,0 +1,295 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// WorkflowRunService is the subset of the GitHub Actions API used to look up the workflow run which produced an
+// artifact. It is satisfied by the Actions service of a *github.Client.
+type WorkflowRunService interface {
+	GetWorkflowRunByID(ctx context.Context, owner, repo string, runID int64) (*github.WorkflowRun, *github.Response, error)
+}
+
+// WithWorkflowRunService uses a custom implementation of the workflow runs API. An ArtifactService which also
+// implements WorkflowRunService is used when this option is omitted.
+func WithWorkflowRunService(service WorkflowRunService) Option {
+	return func(a *App) {
+		a.workflowRuns = service
+	}
+}
+
+// runFilter matches artifacts by the workflow run which produced them. Empty conditions always match.
+type runFilter struct {
+	workflow   string
+	branch     string
+	headSHA    string
+	event      string
+	conclusion string
+}
+
+func (a *App) runFilter() runFilter {
+	return runFilter{workflow: a.Workflow, branch: a.Branch, headSHA: a.HeadSHA, event: a.Event, conclusion: a.Conclusion}
+}
+
+// empty is true when the filter has no conditions
+func (f runFilter) empty() bool {
+	return f == runFilter{}
+}
+
+// needsLookup is true when the filter has conditions on fields only the workflow run holds. The branch and head SHA
+// are listed along with each artifact.
+func (f runFilter) needsLookup() bool {
+	return len(f.workflow) > 0 || len(f.event) > 0 || len(f.conclusion) > 0
+}
+
+func (f runFilter) matches(run *github.WorkflowRun) bool {
+	if len(f.workflow) > 0 && f.workflow != run.GetName() && f.workflow != run.GetPath() && f.workflow != path.Base(run.GetPath()) {
+		return false
+	}
+	if len(f.branch) > 0 && !globMatch(f.branch, run.GetHeadBranch()) {
+		return false
+	}
+	// a short SHA matches the full SHA it abbreviates
+	if len(f.headSHA) > 0 && (len(run.GetHeadSHA()) == 0 || !strings.HasPrefix(run.GetHeadSHA(), strings.ToLower(f.headSHA))) {
+		return false
+	}
+	if len(f.event) > 0 && f.event != run.GetEvent() {
+		return false
+	}
+	if len(f.conclusion) > 0 && f.conclusion != run.GetConclusion() {
+		return false
+	}
+	return true
+}
+
+// globMatch reports whether s matches pattern, where * matches any run of characters (including /) and ? matches any
+// single character
+func globMatch(pattern, s string) bool {
+	p, i := 0, 0
+	star, backtrack := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, backtrack = p, i
+			p++
+		case star >= 0:
+			p = star + 1
+			backtrack++
+			i = backtrack
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// needsRuns is true when the filters in effect match on fields only the workflow run holds
+func (a *App) needsRuns() bool {
+	if a.ClosedPRs {
+		return true
+	}
+	if a.Policy != nil {
+		return a.Policy.needsRuns()
+	}
+	return a.runFilter().needsLookup()
+}
+
+// runCache holds the workflow runs and pull requests looked up while filtering, so that each is fetched once. Runs
+// and pull requests which no longer exist are held as nil.
+type runCache struct {
+	mu   sync.Mutex
+	runs map[int64]*github.WorkflowRun
+	// pulls are the pull requests by owner/repo#number, and runPulls the numbers of the pull requests of each run
+	pulls    map[string]*github.PullRequest
+	runPulls map[int64][]int
+	// tags are the tagged commits by owner/repo, held as nil when the tags couldn't be listed
+	tags map[string]map[string]bool
+}
+
+func newRunCache() *runCache {
+	return &runCache{
+		runs:     make(map[int64]*github.WorkflowRun),
+		pulls:    make(map[string]*github.PullRequest),
+		runPulls: make(map[int64][]int),
+		tags:     make(map[string]map[string]bool),
+	}
+}
+
+func (c *runCache) get(id int64) (*github.WorkflowRun, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	run, ok := c.runs[id]
+	return run, ok
+}
+
+func (c *runCache) put(id int64, run *github.WorkflowRun) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.runs[id] = run
+}
+
+func (c *runCache) pull(key string) (*github.PullRequest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pull, ok := c.pulls[key]
+	return pull, ok
+}
+
+func (c *runCache) putPull(key string, pull *github.PullRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pulls[key] = pull
+}
+
+func (c *runCache) pullsOf(runID int64) ([]int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	numbers, ok := c.runPulls[runID]
+	return numbers, ok
+}
+
+func (c *runCache) putPullsOf(runID int64, numbers []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.runPulls[runID] = numbers
+}
+
+func (c *runCache) tagsOf(repo string) (map[string]bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tagged, ok := c.tags[repo]
+	return tagged, ok
+}
+
+func (c *runCache) putTagsOf(repo string, tagged map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tags[repo] = tagged
+}
+
+// resolveRuns looks up the workflow runs of artifacts which aren't cached yet, up to PageConcurrency at a time, along
+// with their pull requests for ClosedPRs, and the tagged commits of the repository for protection rules. Runs which
+// can't be looked up are logged, and the artifacts they produced don't match any workflow, event or conclusion.
+func (a *App) resolveRuns(ctx context.Context, artifacts []*github.Artifact) {
+	if a.runs == nil {
+		return
+	}
+	if a.protectsTagged() {
+		a.resolveTags(ctx)
+	}
+	if a.needsRuns() {
+		a.lookupRuns(ctx, artifacts)
+	}
+
+	if a.ClosedPRs {
+		a.resolvePullRequests(ctx, artifacts)
+	}
+}
+
+// lookupRuns looks up the workflow runs of artifacts which aren't cached yet, up to PageConcurrency at a time
+func (a *App) lookupRuns(ctx context.Context, artifacts []*github.Artifact) {
+	if a.runs == nil || a.workflowRuns == nil {
+		return
+	}
+
+	ids := make([]int64, 0)
+	seen := make(map[int64]bool)
+	for _, artifact := range artifacts {
+		id := artifact.GetWorkflowRun().GetID()
+		if _, cached := a.runs.get(id); id == 0 || cached || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) > 0 {
+		a.log().WithFields(log.Fields{"runs": len(ids)}).Debug("Looking up workflow runs.")
+		gate := &rateGate{}
+		a.concurrently(ctx, len(ids), func(i int) {
+			a.resolveRun(ctx, gate, ids[i])
+		})
+	}
+}
+
+// concurrently calls fn for each of n items, up to PageConcurrency at a time. No further calls start once ctx is done.
+func (a *App) concurrently(ctx context.Context, n int, fn func(i int)) {
+	concurrency := a.PageConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultPageConcurrency
+	}
+	parallel(ctx, concurrency, n, fn)
+}
+
+// parallel calls fn for each of n items, up to concurrency at a time. No further calls start once ctx is done.
+func parallel(ctx context.Context, concurrency int, n int, fn func(i int)) {
+	slots := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	for i := 0; i < n; i++ {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(i)
+		}()
+	}
+}
+
+func (a *App) resolveRun(ctx context.Context, gate *rateGate, id int64) {
+	ctx, cancel := within(ctx, a.Timeouts.Request)
+	defer cancel()
+
+	var run *github.WorkflowRun
+	resp, err := a.withRetry(ctx, gate, func(ctx context.Context) (resp *github.Response, err error) {
+		run, resp, err = a.workflowRuns.GetWorkflowRunByID(ctx, *a.Owner, *a.Repo, id)
+		return resp, err
+	})
+	switch {
+	case err != nil && statusCode(resp) == http.StatusNotFound:
+		a.log().WithFields(log.Fields{"runId": id}).Debug("Workflow run no longer exists.")
+		a.runs.put(id, nil)
+	case err != nil:
+		a.log().WithError(err).WithFields(log.Fields{"runId": id}).Warn("Unable to look up the workflow run. Its artifacts will not match run filters.")
+	default:
+		a.runs.put(id, run)
+	}
+}
+
+// runOf returns the workflow run which produced an artifact. Without a cached run, the run is built from the branch
+// and head SHA listed along with the artifact.
+func (a *App) runOf(artifact *github.Artifact) *github.WorkflowRun {
+	id := artifact.GetWorkflowRun().GetID()
+	if a.runs != nil {
+		if run, ok := a.runs.get(id); ok && run != nil {
+			return run
+		}
+	}
+	return listedRun(artifact)
+}
+
+// listedRun returns the branch and head SHA of the workflow run listed along with an artifact
+func listedRun(artifact *github.Artifact) *github.WorkflowRun {
+	listed := artifact.GetWorkflowRun()
+	if listed == nil {
+		return &github.WorkflowRun{}
+	}
+	return &github.WorkflowRun{ID: listed.ID, HeadBranch: listed.HeadBranch, HeadSHA: listed.HeadSHA}
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+func createRun(id int64, name, path, branch, sha, event, conclusion string) *github.WorkflowRun {
+	return &github.WorkflowRun{
+		ID:         &id,
+		Name:       &name,
+		Path:       &path,
+		HeadBranch: &branch,
+		HeadSHA:    &sha,
+		Event:      &event,
+		Conclusion: &conclusion,
+	}
+}
+
+// createArtifactOfRun creates an artifact created age ago, listed along with the branch and head SHA of its run
+func createArtifactOfRun(id int64, run *github.WorkflowRun, age time.Duration) *github.Artifact {
+	artifact := createServedArtifact(id, "dist", 100, run.GetID())
+	artifact.CreatedAt = &github.Timestamp{Time: time.Now().Add(-age)}
+	artifact.WorkflowRun.HeadBranch = run.HeadBranch
+	artifact.WorkflowRun.HeadSHA = run.HeadSHA
+	return artifact
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"main", "main", true},
+		{"main", "maintenance", false},
+		{"feature/*", "feature/login", true},
+		{"feature/*", "feature/auth/login", true},
+		{"feature/*", "main", false},
+		{"*", "", true},
+		{"release-?.x", "release-1.x", true},
+		{"release-?.x", "release-10.x", false},
+		{"*-hotfix", "v2-hotfix", true},
+		{"*a*b", "xaxxb", true},
+		{"*a*b", "xaxxbc", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.s, func(t *testing.T) {
+			if got := globMatch(tt.pattern, tt.s); got != tt.want {
+				t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunFilter_Matches(t *testing.T) {
+	run := createRun(1, "CI", ".github/workflows/ci.yml", "feature/login", "0123456789abcdef", "pull_request", "failure")
+	tests := []struct {
+		name   string
+		filter runFilter
+		want   bool
+	}{
+		{"empty", runFilter{}, true},
+		{"workflow name", runFilter{workflow: "CI"}, true},
+		{"workflow file", runFilter{workflow: "ci.yml"}, true},
+		{"workflow path", runFilter{workflow: ".github/workflows/ci.yml"}, true},
+		{"other workflow", runFilter{workflow: "nightly.yml"}, false},
+		{"branch glob", runFilter{branch: "feature/*"}, true},
+		{"other branch", runFilter{branch: "main"}, false},
+		{"short sha", runFilter{headSHA: "0123ABC"}, false},
+		{"sha prefix", runFilter{headSHA: "0123456"}, true},
+		{"event", runFilter{event: "pull_request"}, true},
+		{"other event", runFilter{event: "push"}, false},
+		{"conclusion", runFilter{conclusion: "failure"}, true},
+		{"all conditions", runFilter{workflow: "ci.yml", branch: "feature/*", event: "pull_request", conclusion: "success"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(run); got != tt.want {
+				t.Errorf("expected match %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRun_RunFilters(t *testing.T) {
+	ci := createRun(1, "CI", ".github/workflows/ci.yml", "main", "aaaa111", "push", "success")
+	pr := createRun(2, "CI", ".github/workflows/ci.yml", "feature/login", "bbbb222", "pull_request", "failure")
+	nightly := createRun(3, "Nightly", ".github/workflows/nightly.yml", "main", "aaaa111", "schedule", "success")
+	// run 4 has been deleted, so only its listed branch is known
+	deleted := createRun(4, "", "", "feature/old", "cccc333", "", "")
+
+	tests := []struct {
+		name        string
+		configure   func(a *App)
+		wantDeleted []int64
+		wantLookups bool
+	}{
+		{"workflow file", func(a *App) { a.Workflow = "ci.yml" }, []int64{1, 2, 3}, true},
+		{"workflow name", func(a *App) { a.Workflow = "Nightly" }, []int64{5}, true},
+		{"branch", func(a *App) { a.Branch = "feature/*" }, []int64{3, 6}, false},
+		{"head sha", func(a *App) { a.HeadSHA = "aaaa" }, []int64{1, 2, 5}, false},
+		{"event", func(a *App) { a.Event = "pull_request" }, []int64{3}, true},
+		{"conclusion and branch", func(a *App) { a.Conclusion = "success"; a.Branch = "main" }, []int64{1, 2, 5}, true},
+		{"policy", func(a *App) {
+			a.Policy = &Policy{Rules: []*Rule{
+				{Name: "main", Action: ActionDelete, Match: Match{Branch: "main", ActiveDuration: "720h"}},
+				{Name: "features", Action: ActionDelete, Match: Match{Branch: "feature/*", ActiveDuration: "72h"}},
+			}}
+			if err := a.Policy.compile(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}, []int64{2, 3, 6}, false},
+		{"policy event", func(a *App) {
+			a.Policy = &Policy{Rules: []*Rule{{Name: "scheduled", Action: ActionDelete, Match: Match{Event: "schedule"}}}}
+			if err := a.Policy.compile(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}, []int64{5}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakegithub.NewServer()
+			defer server.Close()
+			server.AddWorkflowRuns("octo-org", "octo-docs", ci, pr, nightly)
+			server.AddArtifacts("octo-org", "octo-docs",
+				createArtifactOfRun(1, ci, time.Hour),
+				createArtifactOfRun(2, ci, 40*24*time.Hour),
+				createArtifactOfRun(3, pr, 4*24*time.Hour),
+				createArtifactOfRun(4, pr, time.Hour),
+				createArtifactOfRun(5, nightly, time.Hour),
+				createArtifactOfRun(6, deleted, 4*24*time.Hour),
+			)
+			// artifact 4 is excluded by size, so its run is only looked up along with artifact 3
+			server.Artifacts("octo-org", "octo-docs")[3].SizeInBytes = github.Ptr(int64(1))
+
+			app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+			app.MinBytes = 100
+			tt.configure(app)
+			if _, err := app.Run(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			deleted := server.Deleted("octo-org", "octo-docs")
+			if len(deleted) != len(tt.wantDeleted) {
+				t.Fatalf("expected %v to be deleted, got %v", tt.wantDeleted, deleted)
+			}
+			want := make(map[int64]bool)
+			for _, id := range tt.wantDeleted {
+				want[id] = true
+			}
+			for _, id := range deleted {
+				if !want[id] {
+					t.Fatalf("expected %v to be deleted, got %v", tt.wantDeleted, deleted)
+				}
+			}
+
+			lookups := make(map[string]int)
+			for _, request := range server.Requests() {
+				if strings.HasPrefix(request, "GET /repos/octo-org/octo-docs/actions/runs/") {
+					lookups[request]++
+				}
+			}
+			if (len(lookups) > 0) != tt.wantLookups {
+				t.Errorf("expected lookups %v, got %v", tt.wantLookups, lookups)
+			}
+			for request, count := range lookups {
+				if count != 1 {
+					t.Errorf("expected each run to be looked up once, got %d of %s", count, request)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_RunFiltersRequireService(t *testing.T) {
+	app, _ := NewWithOptions(WithArtifactService(&fakeArtifactService{perPage: 10}), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+	app.Event = "push"
+	if _, err := app.Run(context.Background()); err == nil {
+		t.Error("expected an error without a workflow run service")
+	}
+}
@@ -0,0 +1,198 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3DateFormat      = "20060102T150405Z"
+)
+
+// S3Store archives artifacts in a bucket of Amazon S3 or an S3-compatible object store, such as MinIO. Objects are
+// addressed path-style (Endpoint/Bucket/Prefix/key), and requests are signed with AWS Signature Version 4.
+type S3Store struct {
+	// Endpoint is the URL of the object store, which defaults to https://s3.{Region}.amazonaws.com
+	Endpoint string
+	// Region of the bucket, which defaults to us-east-1
+	Region string
+	Bucket string
+	// Prefix is prepended to the key of every object, such as backups/artifacts
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set when authenticating with temporary credentials
+	SessionToken string
+	// Client sends the requests, which defaults to http.DefaultClient
+	Client *http.Client
+}
+
+func (s *S3Store) validate() error {
+	if len(s.Bucket) == 0 {
+		return errors.New("s3 archive requires a bucket")
+	}
+	if len(s.AccessKeyID) == 0 || len(s.SecretAccessKey) == 0 {
+		return errors.New("s3 archive requires an access key id and secret access key")
+	}
+	return nil
+}
+
+func (s *S3Store) region() string {
+	if len(s.Region) == 0 {
+		return "us-east-1"
+	}
+	return s.Region
+}
+
+// Put uploads an object of size bytes
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	endpoint := s.Endpoint
+	if len(endpoint) == 0 {
+		endpoint = "https://s3." + s.region() + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("s3 endpoint is invalid: %w", err)
+	}
+
+	objectKey := strings.TrimPrefix(key, "/")
+	if prefix := strings.Trim(s.Prefix, "/"); len(prefix) > 0 {
+		objectKey = prefix + "/" + objectKey
+	}
+	// the path is escaped as it is signed
+	u.RawPath = strings.TrimSuffix(u.EscapedPath(), "/") + "/" + s3Escape(s.Bucket, false) + "/" + s3Escape(objectKey, true)
+	if u.Path, err = url.PathUnescape(u.RawPath); err != nil {
+		return fmt.Errorf("s3 object key is invalid: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	if len(s.SessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	signV4(req, s3UnsignedPayload, s.AccessKeyID, s.SecretAccessKey, s.region(), time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 upload of %s failed: %s: %s", objectKey, resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// signV4 signs a request to S3 with AWS Signature Version 4, signing the host and every header of the request
+func signV4(req *http.Request, payloadHash, accessKeyID, secretAccessKey, region string, now time.Time) {
+	amzDate := now.UTC().Format(s3DateFormat)
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if len(host) == 0 {
+		host = req.URL.Host
+	}
+	names := []string{"host"}
+	values := map[string]string{"host": host}
+	for name, v := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "authorization" {
+			continue
+		}
+		names = append(names, lower)
+		values[lower] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	sort.Strings(names)
+	headers := &strings.Builder{}
+	for _, name := range names {
+		headers.WriteString(name + ":" + values[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		headers.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	scope := date + "/" + region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes every byte but the unreserved characters of RFC 3986, and / when keepSlash is set
+func s3Escape(s string, keepSlash bool) string {
+	escaped := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			escaped.WriteByte(c)
+		case c == '/' && keepSlash:
+			escaped.WriteByte(c)
+		default:
+			_, _ = fmt.Fprintf(escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,285 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// statsUnknown groups artifacts whose workflow, branch or age isn't known
+const statsUnknown = "unknown"
+
+// ageBucket is an age group of Stats, holding artifacts younger than under
+type ageBucket struct {
+	key   string
+	under time.Duration
+}
+
+// ageBuckets are the age groups of Stats, in order. Older artifacts fall in the last group.
+var ageBuckets = []ageBucket{
+	{"<1d", 24 * time.Hour},
+	{"1d-7d", 7 * 24 * time.Hour},
+	{"7d-30d", 30 * 24 * time.Hour},
+	{"30d-90d", 90 * 24 * time.Hour},
+	{">=90d", 0},
+}
+
+// StatsGroup totals the artifacts of a repository, or those of one group of a breakdown
+type StatsGroup struct {
+	Key          string `json:"key,omitempty"`
+	Count        int    `json:"count"`
+	Bytes        int64  `json:"bytes"`
+	Live         int    `json:"live"`
+	LiveBytes    int64  `json:"live_bytes"`
+	Expired      int    `json:"expired"`
+	ExpiredBytes int64  `json:"expired_bytes"`
+	// Selected counts the artifacts which the filters select for deletion
+	Selected int `json:"selected"`
+	// Savings is the projected storage reclaimed by deleting the selected artifacts. Expired artifacts no longer use
+	// storage, so only live ones count.
+	Savings int64 `json:"savings_bytes"`
+}
+
+func (g *StatsGroup) add(artifact *github.Artifact, selected bool) {
+	size := artifact.GetSizeInBytes()
+	g.Count++
+	g.Bytes += size
+	if artifact.GetExpired() {
+		g.Expired++
+		g.ExpiredBytes += size
+	} else {
+		g.Live++
+		g.LiveBytes += size
+	}
+	if selected {
+		g.Selected++
+		if !artifact.GetExpired() {
+			g.Savings += size
+		}
+	}
+}
+
+// Stats is the storage used by the artifacts of a repository, in total and broken down by artifact name, name prefix,
+// workflow, branch and age
+type Stats struct {
+	Owner       string        `json:"owner"`
+	Repo        string        `json:"repo"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Total       *StatsGroup   `json:"total"`
+	ByName      []*StatsGroup `json:"by_name"`
+	ByPrefix    []*StatsGroup `json:"by_prefix"`
+	// ByWorkflow is omitted unless the workflow runs of the artifacts were looked up
+	ByWorkflow []*StatsGroup `json:"by_workflow,omitempty"`
+	ByBranch   []*StatsGroup `json:"by_branch"`
+	ByAge      []*StatsGroup `json:"by_age"`
+}
+
+// Stats lists every artifact of the repository and totals their storage, without deleting anything. Artifacts which
+// the filters select, exactly as Run would, including the protections and deletion caps, are counted as selected,
+// projecting the savings of deleting them. The branch is listed with each artifact, but finding its workflow costs one
+// request per run, so the workflow breakdown is only collected when StatsRuns is set or the filters look up runs anyway.
+func (a *App) Stats(ctx context.Context) (*Stats, error) {
+	if len(a.Org) > 0 {
+		return nil, errors.New("stats supports a single repository, not an org")
+	}
+	if err := a.checkPreconditions(); err != nil {
+		return nil, err
+	}
+
+	a.log().WithFields(log.Fields{"owner": *a.Owner, "repo": *a.Repo}).Info("delete-artifacts is collecting the stats of the repo")
+	executionContext, cancel := within(ctx, a.Timeouts.Run)
+	defer cancel()
+
+	byWorkflow := a.StatsRuns || a.needsRuns()
+	listed := make([]*github.Artifact, 0)
+	// the report is discarded, stats only project which artifacts a deletion would select
+	report := newReport(*a.Owner, *a.Repo)
+	selected, err := a.selectListed(executionContext, report, func(items []*github.Artifact) {
+		listed = append(listed, items...)
+		if a.StatsRuns {
+			a.lookupRuns(executionContext, items)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if selected, err = a.limitDeletions(selected, report); err != nil {
+		// a deletion over the caps would abort, reclaiming nothing
+		selected = nil
+	}
+
+	stats := a.collectStats(listed, selected, time.Now())
+	if !byWorkflow {
+		stats.ByWorkflow = nil
+	}
+	return stats, nil
+}
+
+// collectStats totals the listed artifacts as of now, counting the selected ones toward the projected savings
+func (a *App) collectStats(listed []*github.Artifact, selected []*github.Artifact, now time.Time) *Stats {
+	isSelected := make(map[int64]bool, len(selected))
+	for _, artifact := range selected {
+		isSelected[artifact.GetID()] = true
+	}
+
+	stats := &Stats{Owner: *a.Owner, Repo: *a.Repo, GeneratedAt: now.UTC(), Total: &StatsGroup{}}
+	byName := make(map[string]*StatsGroup)
+	byPrefix := make(map[string]*StatsGroup)
+	byWorkflow := make(map[string]*StatsGroup)
+	byBranch := make(map[string]*StatsGroup)
+	byAge := make(map[string]*StatsGroup)
+	for _, artifact := range listed {
+		chosen := isSelected[artifact.GetID()]
+		run := a.runOf(artifact)
+		stats.Total.add(artifact, chosen)
+		group(byName, artifact.GetName()).add(artifact, chosen)
+		group(byPrefix, namePrefix(artifact.GetName())).add(artifact, chosen)
+		group(byWorkflow, workflowOf(run)).add(artifact, chosen)
+		group(byBranch, orUnknown(run.GetHeadBranch())).add(artifact, chosen)
+		group(byAge, ageOf(artifact, now)).add(artifact, chosen)
+	}
+
+	stats.ByName = largestFirst(byName)
+	stats.ByPrefix = largestFirst(byPrefix)
+	stats.ByWorkflow = largestFirst(byWorkflow)
+	stats.ByBranch = largestFirst(byBranch)
+	stats.ByAge = make([]*StatsGroup, 0, len(byAge))
+	for _, bucket := range append(ageBuckets, ageBucket{key: statsUnknown}) {
+		if g, ok := byAge[bucket.key]; ok {
+			stats.ByAge = append(stats.ByAge, g)
+		}
+	}
+	return stats
+}
+
+func group(groups map[string]*StatsGroup, key string) *StatsGroup {
+	g, ok := groups[key]
+	if !ok {
+		g = &StatsGroup{Key: key}
+		groups[key] = g
+	}
+	return g
+}
+
+// largestFirst orders groups by their storage, largest first, and then by key
+func largestFirst(groups map[string]*StatsGroup) []*StatsGroup {
+	sorted := make([]*StatsGroup, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Bytes != sorted[j].Bytes {
+			return sorted[i].Bytes > sorted[j].Bytes
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	return sorted
+}
+
+// namePrefix returns the part of an artifact name before its first -, _ or ., such that coverage-linux and
+// coverage-windows share the prefix coverage
+func namePrefix(name string) string {
+	if i := strings.IndexAny(name, "-_."); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// workflowOf names the workflow of a run by its file, such as ci.yml, or by its name when the file isn't known
+func workflowOf(run *github.WorkflowRun) string {
+	if len(run.GetPath()) > 0 {
+		return path.Base(run.GetPath())
+	}
+	return orUnknown(run.GetName())
+}
+
+func orUnknown(s string) string {
+	if len(s) == 0 {
+		return statsUnknown
+	}
+	return s
+}
+
+func ageOf(artifact *github.Artifact, now time.Time) string {
+	if artifact.CreatedAt == nil {
+		return statsUnknown
+	}
+	age := now.Sub(artifact.GetCreatedAt().Time)
+	for _, bucket := range ageBuckets {
+		if bucket.under == 0 || age < bucket.under {
+			return bucket.key
+		}
+	}
+	return statsUnknown
+}
+
+// WriteStats writes stats to w as json, or as a table showing the top groups of each breakdown by storage. A top of 0
+// shows every group. The age breakdown is always shown in full.
+func WriteStats(w io.Writer, format string, stats *Stats, top int) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	case FormatTable:
+		return writeStatsTable(w, stats, top)
+	default:
+		return fmt.Errorf("stats format %q is invalid, expected %s or %s", format, FormatJSON, FormatTable)
+	}
+}
+
+func writeStatsTable(w io.Writer, stats *Stats, top int) error {
+	total := stats.Total
+	_, err := fmt.Fprintf(w, "%s/%s: %d artifacts, %s (live: %d, %s; expired: %d, %s)\n"+
+		"selected by the filters: %d artifacts, projected savings %s\n",
+		stats.Owner, stats.Repo, total.Count, formatBytes(total.Bytes), total.Live, formatBytes(total.LiveBytes),
+		total.Expired, formatBytes(total.ExpiredBytes), total.Selected, formatBytes(total.Savings))
+	if err != nil {
+		return err
+	}
+
+	breakdowns := []struct {
+		title  string
+		groups []*StatsGroup
+		top    int
+	}{
+		{"NAME", stats.ByName, top},
+		{"PREFIX", stats.ByPrefix, top},
+		{"WORKFLOW", stats.ByWorkflow, top},
+		{"BRANCH", stats.ByBranch, top},
+		{"AGE", stats.ByAge, 0},
+	}
+	for _, breakdown := range breakdowns {
+		if breakdown.groups == nil {
+			continue
+		}
+		_, _ = fmt.Fprintln(w)
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(writer, "%s\tARTIFACTS\tSIZE\tLIVE\tEXPIRED\tSELECTED\tSAVINGS\n", breakdown.title)
+		shown := breakdown.groups
+		if breakdown.top > 0 && len(shown) > breakdown.top {
+			shown = shown[:breakdown.top]
+		}
+		for _, g := range shown {
+			_, _ = fmt.Fprintf(writer, "%s\t%d\t%s\t%d\t%d\t%d\t%s\n",
+				g.Key, g.Count, formatBytes(g.Bytes), g.Live, g.Expired, g.Selected, formatBytes(g.Savings))
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		if more := len(breakdown.groups) - len(shown); more > 0 {
+			_, _ = fmt.Fprintf(w, "… and %d more\n", more)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/jimschubert/delete-artifacts/fakegithub"
+)
+
+// statsArtifact creates an artifact of run, created age ago
+func statsArtifact(id int64, name string, size int64, run *github.WorkflowRun, age time.Duration) *github.Artifact {
+	artifact := createArtifactOfRun(id, run, age)
+	artifact.Name = &name
+	artifact.SizeInBytes = &size
+	return artifact
+}
+
+func keys(groups []*StatsGroup) string {
+	result := make([]string, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, g.Key)
+	}
+	return strings.Join(result, ",")
+}
+
+func TestStats(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	ci := createRun(1, "CI", ".github/workflows/ci.yml", "main", "abc", "push", "success")
+	nightly := createRun(2, "Nightly", ".github/workflows/nightly.yml", "release/1.0", "def", "schedule", "success")
+	server.AddWorkflowRuns("octo-org", "octo-docs", ci, nightly)
+
+	expired := statsArtifact(4, "logs", 400, nightly, 100*24*time.Hour)
+	expired.Expired = github.Ptr(true)
+	server.AddArtifacts("octo-org", "octo-docs",
+		statsArtifact(1, "coverage-linux", 100, ci, time.Hour),
+		statsArtifact(2, "coverage-windows", 200, ci, 3*24*time.Hour),
+		statsArtifact(3, "dist", 1000, nightly, 40*24*time.Hour),
+		expired,
+	)
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+	app.MinBytes = 150
+	app.StatsRuns = true
+	stats, err := app.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted := server.Deleted("octo-org", "octo-docs"); len(deleted) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v", deleted)
+	}
+
+	want := StatsGroup{Count: 4, Bytes: 1700, Live: 3, LiveBytes: 1300, Expired: 1, ExpiredBytes: 400, Selected: 3, Savings: 1200}
+	if *stats.Total != want {
+		t.Errorf("expected total %+v, got %+v", want, *stats.Total)
+	}
+
+	tests := []struct {
+		name   string
+		groups []*StatsGroup
+		want   string
+	}{
+		{"name", stats.ByName, "dist,logs,coverage-windows,coverage-linux"},
+		{"prefix", stats.ByPrefix, "dist,logs,coverage"},
+		{"workflow", stats.ByWorkflow, "nightly.yml,ci.yml"},
+		{"branch", stats.ByBranch, "release/1.0,main"},
+		{"age", stats.ByAge, "<1d,1d-7d,30d-90d,>=90d"},
+	}
+	for _, tt := range tests {
+		if got := keys(tt.groups); got != tt.want {
+			t.Errorf("expected the %s breakdown %s, got %s", tt.name, tt.want, got)
+		}
+	}
+
+	coverage := stats.ByPrefix[2]
+	if coverage.Count != 2 || coverage.Bytes != 300 || coverage.Selected != 1 || coverage.Savings != 200 {
+		t.Errorf("unexpected coverage prefix %+v", coverage)
+	}
+	// expired artifacts no longer use storage, so deleting them saves nothing
+	logs := stats.ByName[1]
+	if logs.Selected != 1 || logs.Savings != 0 {
+		t.Errorf("unexpected logs %+v", logs)
+	}
+}
+
+func TestStats_WithoutWorkflowRuns(t *testing.T) {
+	service := &fakeArtifactService{artifacts: []*github.Artifact{createServedArtifact(1, "dist", 100, 1)}, perPage: 100}
+	app, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+	app.StatsRuns = true
+	stats, err := app.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keys(stats.ByWorkflow) != statsUnknown || keys(stats.ByBranch) != statsUnknown {
+		t.Errorf("expected the workflow and branch to be unknown, got %s and %s", keys(stats.ByWorkflow), keys(stats.ByBranch))
+	}
+}
+
+func TestStats_OmitsWorkflowBreakdown(t *testing.T) {
+	server := fakegithub.NewServer()
+	defer server.Close()
+	ci := createRun(1, "CI", ".github/workflows/ci.yml", "main", "abc", "push", "success")
+	server.AddWorkflowRuns("octo-org", "octo-docs", ci)
+	server.AddArtifacts("octo-org", "octo-docs", newArtifact("dist", 100, withID(1), withRun(ci)))
+
+	app := newServedApp(t, server, WithRepository("octo-org", "octo-docs"))
+	stats, err := app.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.ByWorkflow != nil {
+		t.Errorf("expected no workflow breakdown without StatsRuns, got %s", keys(stats.ByWorkflow))
+	}
+	// the branch is listed with each artifact, so it needs no lookup
+	if keys(stats.ByBranch) != "main" {
+		t.Errorf("expected the branch breakdown main, got %s", keys(stats.ByBranch))
+	}
+
+	out := &bytes.Buffer{}
+	if err := WriteStats(out, FormatTable, stats, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(out.String(), "WORKFLOW") || !strings.Contains(out.String(), "BRANCH") {
+		t.Errorf("expected the table to omit only the workflow breakdown, got\n%s", out.String())
+	}
+}
+
+func TestStats_SelectsAsDelete(t *testing.T) {
+	now := time.Now()
+	artifacts := []*github.Artifact{
+		newArtifact("dist", 300, withID(1), withCreatedAt(now.Add(-3*time.Hour))),
+		newArtifact("coverage", 200, withID(2), withCreatedAt(now.Add(-2*time.Hour))),
+		newArtifact("logs", 100, withID(3), withCreatedAt(now.Add(-1*time.Hour))),
+	}
+
+	tests := []struct {
+		name         string
+		configure    func(app *App)
+		wantSelected int
+		wantSavings  int64
+	}{
+		{"every artifact", func(app *App) {}, 3, 600},
+		{"protected", func(app *App) { app.Protect = []string{"name:dist"} }, 2, 300},
+		{"capped oldest first", func(app *App) { app.MaxDelete = 1; app.MaxDeleteOrder = MaxDeleteOldest }, 1, 300},
+		{"capped largest first", func(app *App) { app.MaxDeleteBytes = 350; app.MaxDeleteOrder = MaxDeleteLargest }, 1, 300},
+		{"over the caps aborts", func(app *App) { app.MaxDelete = 1 }, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeArtifactService{artifacts: artifacts, perPage: 100}
+			app, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+			tt.configure(app)
+			stats, err := app.Stats(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if stats.Total.Count != 3 || stats.Total.Selected != tt.wantSelected || stats.Total.Savings != tt.wantSavings {
+				t.Errorf("expected 3 artifacts with %d selected saving %d, got %+v", tt.wantSelected, tt.wantSavings, *stats.Total)
+			}
+		})
+	}
+}
+
+func TestStats_RejectsOrg(t *testing.T) {
+	app, _ := NewWithOptions(WithArtifactService(&fakeArtifactService{}), WithLogger(quietLogger()))
+	app.Org = "octo-org"
+	if _, err := app.Stats(context.Background()); err == nil || !strings.Contains(err.Error(), "not an org") {
+		t.Errorf("expected stats of an org to fail, got %v", err)
+	}
+}
+
+func TestNamePrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"coverage-linux-amd64", "coverage"},
+		{"test_results", "test"},
+		{"site.tar", "site"},
+		{"dist", "dist"},
+		{".cache", ".cache"},
+	}
+	for _, tt := range tests {
+		if got := namePrefix(tt.name); got != tt.want {
+			t.Errorf("namePrefix(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriteStats(t *testing.T) {
+	now := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	app := &App{Owner: github.Ptr("octo-org"), Repo: github.Ptr("octo-docs")}
+	listed := []*github.Artifact{
+		createServedArtifact(1, "a-1", 3000, 1),
+		createServedArtifact(2, "b-1", 2000, 1),
+		createServedArtifact(3, "c-1", 1000, 1),
+	}
+	for _, artifact := range listed {
+		artifact.CreatedAt = &github.Timestamp{Time: now.Add(-time.Hour)}
+	}
+	stats := app.collectStats(listed, listed[:1], now)
+
+	out := &bytes.Buffer{}
+	if err := WriteStats(out, FormatTable, stats, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	table := out.String()
+	for _, want := range []string{
+		"octo-org/octo-docs: 3 artifacts, 6.0 kB (live: 3, 6.0 kB; expired: 0, 0 B)",
+		"projected savings 3.0 kB",
+		"NAME  ARTIFACTS  SIZE    LIVE  EXPIRED  SELECTED  SAVINGS",
+		"a-1   1          3.0 kB  1     0        1         3.0 kB",
+		"… and 1 more",
+		"<1d  3",
+	} {
+		if !strings.Contains(table, want) {
+			t.Errorf("expected the table to contain %q, got\n%s", want, table)
+		}
+	}
+	if strings.Contains(table, "c-1") {
+		t.Errorf("expected only the top 2 names, got\n%s", table)
+	}
+
+	out.Reset()
+	if err := WriteStats(out, FormatJSON, stats, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded Stats
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(decoded.ByName) != 3 || decoded.Total.Savings != 3000 || !decoded.GeneratedAt.Equal(now) {
+		t.Errorf("expected every group in json, got %+v", decoded)
+	}
+
+	if err := WriteStats(out, FormatCSV, stats, 0); err == nil {
+		t.Error("expected csv to be unsupported")
+	}
+}
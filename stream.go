@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+
+	"github.com/google/go-github/v75/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// streamArtifacts deletes the matches of each page while the next page is listed, rather than listing every artifact
+// before deleting any. Deleting an artifact shifts those listed after it onto earlier pages, so the first page is
+// listed to learn the total count, and the remaining pages are listed from the last to the second.
+func (a *App) streamArtifacts(ctx context.Context, report *Report) error {
+	gate := &rateGate{}
+	first, err := a.listPage(ctx, gate, 1)
+	if err != nil {
+		return err
+	}
+
+	pages := make(chan []*github.Artifact, 1)
+	errs := make(chan error, 1)
+	go a.listRemainingPages(ctx, gate, first, pages, errs)
+
+	pool := a.startDeleters(ctx, report)
+	// an artifact may appear on two pages when artifacts are created or deleted by others during the listing
+	seen := make(map[int64]bool)
+	selected := 0
+	for items := range pages {
+		a.resolveRuns(ctx, items)
+		filtered, protected := a.protect(a.matchArtifacts(items))
+		report.addMatched(len(protected))
+		a.reportProtected(protected, report)
+
+		matched := make([]*github.Artifact, 0)
+		for _, artifact := range filtered {
+			if !seen[artifact.GetID()] {
+				seen[artifact.GetID()] = true
+				matched = append(matched, artifact)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		a.log().WithFields(log.Fields{"count": len(matched)}).Debug("Found a set of artifacts for slated deletion.")
+		selected += len(matched)
+		report.addMatched(len(matched))
+		if a.DryRun {
+			a.skipDryRun(matched, report)
+		} else {
+			pool.submit(ctx, matched)
+		}
+	}
+	pool.close()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if selected == 0 {
+		a.log().Info("No artifacts to delete!")
+	}
+	return nil
+}
+
+// listRemainingPages sends the pages after first from the last to the second, then first itself, closing pages once
+// done. Listing stops at the first error, which is sent to errs.
+func (a *App) listRemainingPages(ctx context.Context, gate *rateGate, first *github.ArtifactList, pages chan<- []*github.Artifact, errs chan<- error) {
+	defer close(pages)
+
+	send := func(items []*github.Artifact) bool {
+		select {
+		case pages <- items:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	if first.TotalCount == nil {
+		// without a total count to count back from, every page is listed before any is sent
+		listed := [][]*github.Artifact{first.Artifacts}
+		err := a.listAfter(ctx, gate, first, func(items []*github.Artifact) bool {
+			listed = append(listed, items)
+			return true
+		})
+		if err != nil {
+			errs <- err
+			return
+		}
+		for _, items := range listed {
+			if !send(items) {
+				return
+			}
+		}
+		return
+	}
+
+	last := lastPage(first)
+	a.log().WithFields(log.Fields{"total": first.GetTotalCount(), "pages": last}).Debug("Listing the remaining pages in reverse.")
+	reversed := make([]int, 0, last)
+	for page := last; page > 1; page-- {
+		reversed = append(reversed, page)
+	}
+	// deleting the artifacts of a page only shifts those of the later pages, which have been listed already
+	more, err := a.fetchPages(ctx, gate, reversed, send)
+	if err != nil {
+		errs <- err
+		return
+	}
+	if more {
+		send(first.Artifacts)
+	}
+}
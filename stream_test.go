@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// pagedService records the pages listed, and holds the listing of holdPage until an artifact is deleted
+type pagedService struct {
+	*fakeArtifactService
+	holdPage   int
+	noTotal    bool
+	mu         sync.Mutex
+	pages      []int
+	deleteOnce sync.Once
+	deletedOne chan struct{}
+}
+
+func (p *pagedService) ListArtifacts(ctx context.Context, owner, repo string, opts *github.ListArtifactsOptions) (*github.ArtifactList, *github.Response, error) {
+	p.mu.Lock()
+	p.pages = append(p.pages, opts.Page)
+	p.mu.Unlock()
+
+	if opts.Page == p.holdPage {
+		select {
+		case <-p.deletedOne:
+		case <-time.After(5 * time.Second):
+			return nil, &github.Response{}, errors.New("page was listed before any artifact was deleted")
+		}
+	}
+	list, resp, err := p.fakeArtifactService.ListArtifacts(ctx, owner, repo, opts)
+	if p.noTotal {
+		list.TotalCount = nil
+	}
+	return list, resp, err
+}
+
+func (p *pagedService) DeleteArtifact(ctx context.Context, owner, repo string, artifactID int64) (*github.Response, error) {
+	p.deleteOnce.Do(func() { close(p.deletedOne) })
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.fakeArtifactService.DeleteArtifact(ctx, owner, repo, artifactID)
+}
+
+func newPagedService(count int64, holdPage int) *pagedService {
+	artifacts := make([]*github.Artifact, 0)
+	for i := int64(1); i <= count; i++ {
+		artifacts = append(artifacts, createServedArtifact(i, "a", 100, 1))
+	}
+	return &pagedService{
+		fakeArtifactService: &fakeArtifactService{artifacts: artifacts, perPage: 10},
+		holdPage:            holdPage,
+		deletedOne:          make(chan struct{}),
+	}
+}
+
+func TestRun_StreamsDeletions(t *testing.T) {
+	// the second page is listed last, and only once deletions of the later pages have started
+	service := newPagedService(35, 2)
+	app, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+	app.PageConcurrency = 1
+
+	report, err := app.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Matched != 35 || report.Deleted != 35 || len(service.deleted) != 35 {
+		t.Errorf("expected all 35 artifacts to be deleted, got %+v", report)
+	}
+	want := []int{1, 4, 3, 2}
+	if len(service.pages) != len(want) {
+		t.Fatalf("expected pages %v to be listed, got %v", want, service.pages)
+	}
+	for i := range want {
+		if service.pages[i] != want[i] {
+			t.Fatalf("expected pages %v to be listed, got %v", want, service.pages)
+		}
+	}
+}
+
+func TestRun_StreamsDryRun(t *testing.T) {
+	service := newPagedService(25, 0)
+	app, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+	app.DryRun = true
+
+	report, err := app.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Matched != 25 || report.Skipped != 25 || len(service.deleted) != 0 {
+		t.Errorf("expected all 25 artifacts to be skipped, got %+v", report)
+	}
+}
+
+func TestRun_StreamsWithoutTotalCount(t *testing.T) {
+	service := newPagedService(25, 0)
+	service.noTotal = true
+	app, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+
+	report, err := app.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Matched != 25 || report.Deleted != 25 {
+		t.Errorf("expected all 25 artifacts to be deleted, got %+v", report)
+	}
+}
+
+func TestRun_StreamsDuplicatesOnce(t *testing.T) {
+	service := newPagedService(15, 0)
+	// an artifact created during the listing pushes the last artifact of the first page onto the second
+	service.artifacts = append(service.artifacts[:10], append([]*github.Artifact{service.artifacts[9]}, service.artifacts[10:]...)...)
+	app, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()))
+
+	report, err := app.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Matched != 15 || len(service.deleted) != 15 {
+		t.Errorf("expected 15 distinct artifacts to be deleted, got deleted %v and %+v", service.deleted, report)
+	}
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// Timeouts bound how long a run and its API calls may take. Zero values don't impose a limit.
+type Timeouts struct {
+	// Run bounds a whole run of a repository, including listing and deleting. During an org sweep, it bounds each
+	// repository, and the listing of the org's repositories.
+	Run time.Duration
+	// Request bounds each list or get call, including its retries
+	Request time.Duration
+	// Delete bounds each delete request. A request which has started is allowed to finish for up to this long after
+	// the run is cancelled.
+	Delete time.Duration
+	// Archive bounds the backup of each artifact, including its download, when artifacts are archived before deletion.
+	// It also bounds each artifact fetched by Download.
+	Archive time.Duration
+}
+
+// DefaultTimeouts bounds a run to 2 minutes, each API call to 30 seconds, and the backup of each artifact to 10 minutes
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Run:     2 * time.Minute,
+		Request: 30 * time.Second,
+		Delete:  30 * time.Second,
+		Archive: 10 * time.Minute,
+	}
+}
+
+// within returns a context which is done after d, or only when ctx is done when d isn't positive
+func within(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// slowService lists artifacts only once the request is done
+type slowService struct {
+	*fakeArtifactService
+}
+
+func (s *slowService) ListArtifacts(ctx context.Context, _, _ string, _ *github.ListArtifactsOptions) (*github.ArtifactList, *github.Response, error) {
+	<-ctx.Done()
+	return nil, &github.Response{}, ctx.Err()
+}
+
+func TestWithin(t *testing.T) {
+	tests := []struct {
+		name         string
+		d            time.Duration
+		wantDeadline bool
+	}{
+		{"positive", time.Minute, true},
+		{"zero", 0, false},
+		{"negative", -time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := within(context.Background(), tt.d)
+			defer cancel()
+			if _, ok := ctx.Deadline(); ok != tt.wantDeadline {
+				t.Errorf("expected deadline %v, got %v", tt.wantDeadline, ok)
+			}
+		})
+	}
+}
+
+func TestRun_Timeouts(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeouts Timeouts
+	}{
+		{"request", Timeouts{Request: 10 * time.Millisecond}},
+		{"run", Timeouts{Run: 10 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &slowService{fakeArtifactService: &fakeArtifactService{perPage: 10}}
+			app, _ := NewWithOptions(WithArtifactService(service), WithRepository("octo-org", "octo-docs"), WithLogger(quietLogger()),
+				WithRetryPolicy(RetryPolicy{MaxAttempts: 1}), WithTimeouts(tt.timeouts))
+
+			report, err := app.Run(context.Background())
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expected the deadline to be exceeded, got %v", err)
+			}
+			if report.Result() != ResultPartialFailure {
+				t.Errorf("expected a timed out run to fail, got %d", report.Result())
+			}
+		})
+	}
+}